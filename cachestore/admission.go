@@ -0,0 +1,335 @@
+package cachestore
+
+import "container/list"
+
+//AdmissionPolicy 准入策略
+//缓存仓已满时，决定新键能否淘汰现有的键写入
+//键以缓存仓的哈希函数计算出的哈希值表示，所有方法都在缓存仓的锁内调用
+type AdmissionPolicy interface {
+	//Record 记录一次对键的访问
+	Record(hash uint64)
+	//Admit 返回candidate是否应替换victim写入
+	Admit(candidate, victim uint64) bool
+}
+
+//count-min sketch的参数
+const (
+	sketchDepth      = 4
+	sketchMaxCount   = 15 //计数器上限，与4位计数器一致
+	sketchMinWidth   = 64
+	sketchSampleRate = 10 //记录次数达到宽度的该倍数时计数减半
+)
+
+//每一行使用的哈希种子
+var sketchSeeds = [sketchDepth]uint64{
+	0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325,
+}
+
+//NewTinyLFU 基于count-min sketch频率估计的准入策略
+//新键只有在估计访问频率高于被淘汰的键时才会写入，避免一次性的顺序扫描冲刷缓存
+//capacity为缓存容量，用于确定sketch的大小；计数器定期减半，使频率随时间衰减
+func NewTinyLFU(capacity int) AdmissionPolicy {
+	return newTinyLFU(capacity)
+}
+
+func newTinyLFU(capacity int) *tinyLFU {
+	width := sketchMinWidth
+	for width < capacity {
+		width <<= 1
+	}
+	t := &tinyLFU{
+		mask:       uint64(width - 1),
+		sampleSize: width * sketchSampleRate,
+	}
+	for i := range t.rows {
+		t.rows[i] = make([]uint8, width)
+	}
+	return t
+}
+
+type tinyLFU struct {
+	door       *doorkeeper //可为nil
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func (t *tinyLFU) Record(hash uint64) {
+	if t.door != nil && !t.door.add(hash) {
+		//第一次出现的键只记入doorkeeper
+		t.addition()
+		return
+	}
+	added := false
+	for i := range t.rows {
+		idx := t.index(hash, i)
+		if t.rows[i][idx] < sketchMaxCount {
+			t.rows[i][idx]++
+			added = true
+		}
+	}
+	if added {
+		t.addition()
+	}
+}
+
+//累计记录次数，达到采样数后计数减半
+func (t *tinyLFU) addition() {
+	if t.additions++; t.additions >= t.sampleSize {
+		t.reset()
+	}
+}
+
+func (t *tinyLFU) Admit(candidate, victim uint64) bool {
+	return t.estimate(candidate) > t.estimate(victim)
+}
+
+//估计访问频率 取各行计数的最小值
+func (t *tinyLFU) estimate(hash uint64) uint8 {
+	min := uint8(sketchMaxCount)
+	for i := range t.rows {
+		if c := t.rows[i][t.index(hash, i)]; c < min {
+			min = c
+		}
+	}
+	if t.door != nil && t.door.contains(hash) {
+		min++
+	}
+	return min
+}
+
+//所有计数减半 使旧的访问逐渐失去影响
+func (t *tinyLFU) reset() {
+	for i := range t.rows {
+		for j := range t.rows[i] {
+			t.rows[i][j] >>= 1
+		}
+	}
+	t.additions /= 2
+	if t.door != nil {
+		t.door.clear()
+	}
+}
+
+func (t *tinyLFU) index(hash uint64, row int) uint64 {
+	return mix64(hash^sketchSeeds[row]) & t.mask
+}
+
+//doorkeeper 布隆过滤器
+//键第一次出现时只记录在doorkeeper中，再次出现才计入sketch，使只访问一次的键不占用sketch的计数
+type doorkeeper struct {
+	bits []uint64
+	mask uint64
+}
+
+//size为位数，向上取整为2的幂
+func newDoorkeeper(size int) *doorkeeper {
+	n := 64
+	for n < size {
+		n <<= 1
+	}
+	return &doorkeeper{bits: make([]uint64, n/64), mask: uint64(n - 1)}
+}
+
+//记录一个键，返回键在记录前是否已存在
+func (d *doorkeeper) add(hash uint64) bool {
+	h := mix64(hash)
+	present := true
+	for _, i := range [2]uint64{h & d.mask, (h >> 32) & d.mask} {
+		if d.bits[i/64]&(1<<(i%64)) == 0 {
+			d.bits[i/64] |= 1 << (i % 64)
+			present = false
+		}
+	}
+	return present
+}
+
+func (d *doorkeeper) contains(hash uint64) bool {
+	h := mix64(hash)
+	for _, i := range [2]uint64{h & d.mask, (h >> 32) & d.mask} {
+		if d.bits[i/64]&(1<<(i%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *doorkeeper) clear() {
+	for i := range d.bits {
+		d.bits[i] = 0
+	}
+}
+
+//W-TinyLFU的区域划分
+const (
+	windowPercent    = 1  //窗口区占总容量的百分比
+	protectedPercent = 80 //保护段占主区的百分比
+)
+
+//W-TinyLFU中键所在的区域
+type wtinyRegion uint8
+
+const (
+	regionWindow    wtinyRegion = iota //窗口区 新写入的键
+	regionProbation                    //主区试用段 从窗口区晋升或从保护段降级的键
+	regionProtected                    //主区保护段 在试用段中再次被访问的键
+)
+
+type wtinyEntry[K comparable] struct {
+	key    K
+	region wtinyRegion
+}
+
+//NewWTinyLFUPolicy W-TinyLFU淘汰策略
+//新键先进入约占容量1%的LRU窗口区，窗口区满后，窗口区最久未访问的键与主区的待淘汰键比较
+//count-min sketch（带doorkeeper）估计的访问频率，频率更高的一方留在主区，另一方被淘汰；
+//主区为分段LRU，试用段中再次被访问的键晋升到保护段
+//窗口区使突发的新热点可以进入缓存，频率比较使一次性的顺序扫描无法冲刷主区
+//capacity为缓存容量，小于等于0时（如只按成本限制）按当前的键数量划分区域；hash为键的哈希函数
+func NewWTinyLFUPolicy[K comparable](capacity int, hash func(K) uint64) EvictionPolicy[K] {
+	sketchSize := capacity
+	if sketchSize <= 0 {
+		sketchSize = sketchMinWidth
+	}
+	//窗口区与主区的比较只看相对频率，sketch取容量的2倍以减少冲突
+	sketch := newTinyLFU(sketchSize * 2)
+	sketch.door = newDoorkeeper(sketch.sampleSize * 2)
+	return &wtinyLFUPolicy[K]{
+		capacity:  capacity,
+		hash:      hash,
+		sketch:    sketch,
+		window:    list.New(),
+		probation: list.New(),
+		protected: list.New(),
+		elems:     make(map[K]*list.Element),
+	}
+}
+
+//链表头部为最久未访问的键
+type wtinyLFUPolicy[K comparable] struct {
+	capacity  int
+	hash      func(K) uint64
+	sketch    *tinyLFU
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	elems     map[K]*list.Element
+}
+
+func (p *wtinyLFUPolicy[K]) Add(key K) {
+	if _, ok := p.elems[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.sketch.Record(p.hash(key))
+	p.elems[key] = p.window.PushBack(&wtinyEntry[K]{key: key, region: regionWindow})
+	//缓存仓未满时窗口区溢出的键直接进入主区
+	for p.window.Len() > p.windowSize() {
+		p.promote(p.window.Front())
+	}
+}
+
+func (p *wtinyLFUPolicy[K]) Access(key K) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+	p.sketch.Record(p.hash(key))
+	entry := e.Value.(*wtinyEntry[K])
+	switch entry.region {
+	case regionWindow:
+		p.window.MoveToBack(e)
+	case regionProbation:
+		p.probation.Remove(e)
+		entry.region = regionProtected
+		p.elems[key] = p.protected.PushBack(entry)
+		//保护段超出上限时最久未访问的键降级到试用段
+		for p.protected.Len() > p.protectedSize() {
+			front := p.protected.Front()
+			demoted := p.protected.Remove(front).(*wtinyEntry[K])
+			demoted.region = regionProbation
+			p.elems[demoted.key] = p.probation.PushBack(demoted)
+		}
+	case regionProtected:
+		p.protected.MoveToBack(e)
+	}
+}
+
+func (p *wtinyLFUPolicy[K]) Remove(key K) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+	p.list(e.Value.(*wtinyEntry[K]).region).Remove(e)
+	delete(p.elems, key)
+}
+
+//Victim 缓存仓已满、新键即将写入窗口区时调用
+//窗口区已满时，窗口区的候选键与主区的待淘汰键比较频率，胜出的候选键晋升到主区
+func (p *wtinyLFUPolicy[K]) Victim() (K, bool) {
+	var key K
+	candidate, victim := p.window.Front(), p.mainVictim()
+	switch {
+	case candidate == nil && victim == nil:
+		return key, false
+	case victim == nil:
+		return candidate.Value.(*wtinyEntry[K]).key, true
+	case candidate == nil || p.window.Len() < p.windowSize():
+		return victim.Value.(*wtinyEntry[K]).key, true
+	}
+	candKey := candidate.Value.(*wtinyEntry[K]).key
+	victimKey := victim.Value.(*wtinyEntry[K]).key
+	if p.sketch.Admit(p.hash(candKey), p.hash(victimKey)) {
+		p.promote(candidate)
+		return victimKey, true
+	}
+	return candKey, true
+}
+
+//主区的待淘汰键 优先淘汰试用段
+func (p *wtinyLFUPolicy[K]) mainVictim() *list.Element {
+	if e := p.probation.Front(); e != nil {
+		return e
+	}
+	return p.protected.Front()
+}
+
+//将窗口区的键移入主区试用段
+func (p *wtinyLFUPolicy[K]) promote(e *list.Element) {
+	entry := p.window.Remove(e).(*wtinyEntry[K])
+	entry.region = regionProbation
+	p.elems[entry.key] = p.probation.PushBack(entry)
+}
+
+//窗口区的大小 至少为1
+func (p *wtinyLFUPolicy[K]) windowSize() int {
+	capacity := p.capacity
+	if capacity <= 0 {
+		capacity = len(p.elems)
+	}
+	if n := capacity * windowPercent / 100; n > 1 {
+		return n
+	}
+	return 1
+}
+
+//保护段的大小上限
+func (p *wtinyLFUPolicy[K]) protectedSize() int {
+	capacity := p.capacity
+	if capacity <= 0 {
+		capacity = len(p.elems)
+	}
+	return (capacity - p.windowSize()) * protectedPercent / 100
+}
+
+func (p *wtinyLFUPolicy[K]) list(region wtinyRegion) *list.List {
+	switch region {
+	case regionWindow:
+		return p.window
+	case regionProbation:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
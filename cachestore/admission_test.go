@@ -0,0 +1,210 @@
+package cachestore
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTinyLFUSketch(t *testing.T) {
+	p := NewTinyLFU(100).(*tinyLFU)
+	for i := 0; i < 5; i++ {
+		p.Record(1)
+	}
+	p.Record(2)
+	if p.estimate(1) != 5 || p.estimate(2) != 1 || p.estimate(3) != 0 {
+		t.Fatalf("estimates = %d %d %d", p.estimate(1), p.estimate(2), p.estimate(3))
+	}
+	if !p.Admit(1, 2) || p.Admit(2, 1) || p.Admit(3, 3) {
+		t.Fatal("admit should prefer the more frequent key")
+	}
+	for i := 0; i < 100; i++ {
+		p.Record(1)
+	}
+	if p.estimate(1) != sketchMaxCount {
+		t.Fatalf("estimate should saturate at %d, got %d", sketchMaxCount, p.estimate(1))
+	}
+	//记录次数达到采样数后计数减半
+	p.additions = p.sampleSize - 1
+	p.Record(2)
+	if p.estimate(1) != sketchMaxCount/2 {
+		t.Fatalf("estimate after reset = %d, want %d", p.estimate(1), sketchMaxCount/2)
+	}
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	cs := NewCacheStore(3, 0, WithLRU[int, int](), WithTinyLFU[int, int]())
+	for k := 1; k <= 3; k++ {
+		cs.Save(k, k)
+		cs.Get(k)
+		cs.Get(k)
+	}
+	//一次性的键无法淘汰热点键
+	for k := 100; k < 200; k++ {
+		if cs.Save(k, k) {
+			t.Fatalf("cold key %d should be rejected", k)
+		}
+	}
+	for k := 1; k <= 3; k++ {
+		if _, ok := cs.GetOK(k); !ok {
+			t.Fatalf("hot key %d was evicted", k)
+		}
+	}
+	if s := cs.Stats(); s.Rejections != 100 || s.Evictions != 0 {
+		t.Fatalf("rejections = %d evictions = %d", s.Rejections, s.Evictions)
+	}
+	//热点键各计3次访问，第4次写入的新键频率更高，可以写入
+	for i := 0; i < 3; i++ {
+		if cs.Save(50, 50) {
+			t.Fatalf("key 50 admitted after %d writes", i+1)
+		}
+	}
+	if !cs.Save(50, 50) {
+		t.Fatal("frequent key should be admitted")
+	}
+	if cs.Len() != 3 {
+		t.Fatalf("len = %d, want 3", cs.Len())
+	}
+	//已存在的键更新不受准入策略影响
+	if !cs.Save(50, 51) || cs.Get(50) != 51 {
+		t.Fatal("update of a resident key should always succeed")
+	}
+}
+
+func TestWTinyLFUPolicy(t *testing.T) {
+	cs := NewCacheStore(100, 0, WithWTinyLFU[int, int]())
+	for k := 0; k < 100; k++ {
+		cs.Save(k, k)
+		for i := 0; i < 3; i++ {
+			cs.Get(k)
+		}
+	}
+	//一次性的键总能写入窗口区，但无法进入主区
+	for k := 1000; k < 2000; k++ {
+		if !cs.Save(k, k) {
+			t.Fatalf("key %d should be written to the window", k)
+		}
+	}
+	if cs.Len() != 100 {
+		t.Fatalf("len = %d, want 100", cs.Len())
+	}
+	hot := 0
+	for k := 0; k < 100; k++ {
+		if _, ok := cs.Peek(k); ok {
+			hot++
+		}
+	}
+	if hot != 99 {
+		t.Fatalf("%d hot keys left after the scan, want 99", hot)
+	}
+	//窗口区中频率更高的键晋升到主区
+	cs.Save(5000, 0)
+	for i := 0; i < 5; i++ {
+		cs.Get(5000)
+	}
+	cs.Save(5001, 0)
+	if _, ok := cs.Peek(5000); !ok {
+		t.Fatal("frequent key should be promoted from the window")
+	}
+	if cs.Len() != 100 || cs.Stats().Rejections != 0 {
+		t.Fatalf("len = %d rejections = %d", cs.Len(), cs.Stats().Rejections)
+	}
+	//删除任意区域中的键
+	for _, k := range []int{5000, 5001, 1} {
+		cs.Delete(k)
+	}
+	if cs.Len() != 97 {
+		t.Fatalf("len = %d after delete, want 97", cs.Len())
+	}
+}
+
+func TestDoorkeeper(t *testing.T) {
+	d := newDoorkeeper(1024)
+	if d.contains(1) || d.add(1) || !d.add(1) || !d.contains(1) {
+		t.Fatal("doorkeeper should remember added keys")
+	}
+	d.clear()
+	if d.contains(1) {
+		t.Fatal("clear should forget all keys")
+	}
+}
+
+const (
+	traceKeys  = 100000
+	traceLen   = 200000
+	traceCache = 1000
+)
+
+//服从Zipf分布的访问序列
+func zipfTrace(seed int64) []int {
+	r := rand.New(rand.NewSource(seed))
+	z := rand.NewZipf(r, 1.1, 1, traceKeys-1)
+	trace := make([]int, traceLen)
+	for i := range trace {
+		trace[i] = int(z.Uint64())
+	}
+	return trace
+}
+
+//Zipf访问中穿插一次性的顺序扫描
+func scanTrace(seed int64) []int {
+	trace := zipfTrace(seed)
+	next := traceKeys
+	for i := 0; i+2*traceCache < len(trace); i += 10 * traceCache {
+		for j := 0; j < 2*traceCache; j++ {
+			trace[i+j] = next
+			next++
+		}
+	}
+	return trace
+}
+
+//Zipf访问的热点集合每隔一段时间整体更换
+func shiftTrace(seed int64) []int {
+	trace := zipfTrace(seed)
+	phase := len(trace) / 4
+	for i := range trace {
+		trace[i] += i / phase * traceKeys
+	}
+	return trace
+}
+
+func benchmarkHitRatio(b *testing.B, trace []int, opts ...Option[int, int]) {
+	var hits, total int
+	for n := 0; n < b.N; n++ {
+		cs := NewCacheStore(traceCache, 0, opts...)
+		for _, k := range trace {
+			if _, ok := cs.GetOK(k); ok {
+				hits++
+			} else {
+				cs.Save(k, k)
+			}
+			total++
+		}
+	}
+	b.ReportMetric(100*float64(hits)/float64(total), "hit%")
+}
+
+func BenchmarkHitRatio(b *testing.B) {
+	traces := []struct {
+		name  string
+		trace []int
+	}{
+		{"zipf", zipfTrace(1)},
+		{"scan", scanTrace(1)},
+		{"shift", shiftTrace(1)},
+	}
+	for _, tr := range traces {
+		b.Run(tr.name+"/lru", func(b *testing.B) {
+			benchmarkHitRatio(b, tr.trace, WithLRU[int, int]())
+		})
+		b.Run(tr.name+"/lru+tinylfu", func(b *testing.B) {
+			benchmarkHitRatio(b, tr.trace, WithLRU[int, int](), WithTinyLFU[int, int]())
+		})
+		b.Run(tr.name+"/lfu", func(b *testing.B) {
+			benchmarkHitRatio(b, tr.trace, WithLFU[int, int]())
+		})
+		b.Run(tr.name+"/wtinylfu", func(b *testing.B) {
+			benchmarkHitRatio(b, tr.trace, WithWTinyLFU[int, int]())
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package cachestore
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+//ErrNotLoaded 批量加载函数没有返回该键的值
+//GetOrLoadMany的结果中会省略这类键，同时在等待的GetOrLoad中作为错误返回
+var ErrNotLoaded = errors.New("cachestore: key not returned by batch loader")
+
+//GetMany 一次加锁读取多个元素
+//返回命中的元素，以及按传入顺序排列的未命中的键
+func (cs *CacheStore[K, V]) GetMany(keys []K) (map[K]V, []K) {
+	found := make(map[K]V, len(keys))
+	var missing []K
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	now := cs.clock.Now()
+	for _, key := range keys {
+		if v, ok := cs.getItem(key, now); ok {
+			found[key] = v
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	return found, missing
+}
+
+//SaveMany 一次加锁保存多个元素，返回成功保存的数量
+//元素逐个写入并按淘汰策略腾出空间，批量大于容量时只会保留其中一部分，map的遍历顺序不固定
+func (cs *CacheStore[K, V]) SaveMany(items map[K]V) int {
+	return cs.SaveManyWithTTL(items, cs.lifetime)
+}
+
+//SaveManyWithTTL 以单独的存活时间一次加锁保存多个元素，返回成功保存的数量
+func (cs *CacheStore[K, V]) SaveManyWithTTL(items map[K]V, ttl time.Duration) int {
+	if ttl < 0 {
+		ttl = 0
+	}
+	if ttl > 0 {
+		cs.startGC()
+	}
+	cs.lock.Lock()
+	defer cs.unlock()
+	now := cs.clock.Now()
+	saved := 0
+	for key, value := range items {
+		cs.cancelLoad(key)
+		if cs.saveItem(key, value, ttl, now) {
+			saved++
+		}
+	}
+	return saved
+}
+
+//DeleteMany 一次加锁删除多个元素，返回实际删除的数量
+func (cs *CacheStore[K, V]) DeleteMany(keys ...K) int {
+	cs.lock.Lock()
+	defer cs.unlock()
+	removed := 0
+	for _, key := range keys {
+		delete(cs.negatives, key)
+		if cs.removeItem(key, EvictDeleted) {
+			removed++
+		}
+	}
+	return removed
+}
+
+//GetOrLoadMany 批量获取元素，未命中的键通过一次loader调用加载并保存
+//loader只会收到未命中且没有正在加载的键，正在由其他调用加载的键会等待其结果
+//loader没有返回的键和处于负缓存中的键不会出现在结果中
+//loader返回错误时，返回已获得的元素与该错误
+func (cs *CacheStore[K, V]) GetOrLoadMany(keys []K, loader func([]K) (map[K]V, error)) (map[K]V, error) {
+	result := make(map[K]V, len(keys))
+	waits := make(map[K]*loadCall[V])
+	calls := make(map[K]*loadCall[V])
+	var missing []K
+
+	cs.lock.Lock()
+	now := cs.clock.Now()
+	for _, key := range keys {
+		if _, ok := result[key]; ok {
+			continue
+		}
+		if _, ok := waits[key]; ok {
+			continue
+		}
+		if _, ok := calls[key]; ok {
+			continue
+		}
+		if v, ok := cs.getItem(key, now); ok {
+			result[key] = v
+			continue
+		}
+		if neg, ok := cs.negatives[key]; ok {
+			if now.Before(neg.gcTime) {
+				continue
+			}
+			delete(cs.negatives, key)
+		}
+		if call, ok := cs.loading[key]; ok {
+			waits[key] = call
+			continue
+		}
+		call := &loadCall[V]{}
+		call.wg.Add(1)
+		cs.loading[key] = call
+		calls[key] = call
+		missing = append(missing, key)
+	}
+	cs.lock.Unlock()
+
+	var loadErr error
+	if len(missing) > 0 {
+		loadErr = cs.doLoadMany(missing, calls, loader)
+		for key, call := range calls {
+			if call.err == nil {
+				result[key] = call.val
+			}
+		}
+	}
+	for key, call := range waits {
+		call.wg.Wait()
+		if call.err == nil {
+			result[key] = call.val
+		} else if loadErr == nil && !errors.Is(call.err, ErrNotLoaded) {
+			loadErr = call.err
+		}
+	}
+	return result, loadErr
+}
+
+//执行批量加载并保存结果，返回loader的错误
+//loader发生panic时转为错误，所有等待方都会被唤醒
+func (cs *CacheStore[K, V]) doLoadMany(keys []K, calls map[K]*loadCall[V], loader func([]K) (map[K]V, error)) (err error) {
+	var values map[K]V
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cachestore: loader panic: %v", r)
+		}
+		atomic.AddUint64(&cs.stats.loads, 1)
+		if err != nil {
+			atomic.AddUint64(&cs.stats.loadErrors, 1)
+		}
+		cs.lock.Lock()
+		now := cs.clock.Now()
+		for _, key := range keys {
+			call := calls[key]
+			cs.finishLoad(key, call)
+			if v, ok := values[key]; ok && err == nil {
+				call.val = v
+				if !call.canceled && cs.saveItem(key, v, cs.lifetime, now) {
+					cs.markLoaded(cs.data[key])
+				}
+				continue
+			}
+			call.err = err
+			if call.err == nil {
+				call.err = ErrNotLoaded
+			}
+			if !call.canceled && cs.negTTL > 0 && cs.data[key] == nil {
+				cs.saveNegative(key, call.err, now)
+			}
+		}
+		cs.unlock()
+		for _, call := range calls {
+			call.wg.Done()
+		}
+	}()
+	values, err = loader(keys)
+	return err
+}
@@ -0,0 +1,174 @@
+package cachestore
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetSaveDeleteMany(t *testing.T) {
+	cs := NewCacheStore[string, int](3, 0)
+	if n := cs.SaveMany(map[string]int{"a": 1, "b": 2}); n != 2 {
+		t.Fatalf("saved %d, want 2", n)
+	}
+	found, missing := cs.GetMany([]string{"a", "x", "b", "y"})
+	if !reflect.DeepEqual(found, map[string]int{"a": 1, "b": 2}) || !reflect.DeepEqual(missing, []string{"x", "y"}) {
+		t.Fatalf("found = %v missing = %v", found, missing)
+	}
+	if s := cs.Stats(); s.Hits != 2 || s.Misses != 2 {
+		t.Fatalf("hits = %d misses = %d", s.Hits, s.Misses)
+	}
+
+	//批量写入超出容量时逐个淘汰
+	var evicted int
+	cs.OnEvict(func(string, int, EvictReason) { evicted++ })
+	cs.SaveMany(map[string]int{"c": 3, "d": 4, "e": 5})
+	if cs.Len() != 3 || evicted != 2 {
+		t.Fatalf("len = %d evicted = %d", cs.Len(), evicted)
+	}
+
+	if n := cs.DeleteMany("c", "d", "e", "zz"); n != 3 {
+		t.Fatalf("deleted %d, want 3", n)
+	}
+	if cs.Len() != 0 {
+		t.Fatalf("len = %d after DeleteMany", cs.Len())
+	}
+
+	clock := NewManualClock(time.Unix(0, 0))
+	ttl := NewCacheStore(0, 0, WithClock[string, int](clock))
+	defer ttl.Close()
+	ttl.SaveManyWithTTL(map[string]int{"a": 1, "b": 2}, time.Second)
+	clock.Advance(2 * time.Second)
+	if _, missing := ttl.GetMany([]string{"a", "b"}); len(missing) != 2 {
+		t.Fatalf("expired items should be missing, got %v", missing)
+	}
+}
+
+func TestGetOrLoadMany(t *testing.T) {
+	cs := NewCacheStore[int, string](0, 0)
+	cs.Save(1, "one")
+	var calls int32
+	var got []int
+	loader := func(keys []int) (map[int]string, error) {
+		atomic.AddInt32(&calls, 1)
+		got = append([]int(nil), keys...)
+		res := make(map[int]string)
+		for _, k := range keys {
+			if k != 4 {
+				res[k] = string(rune('a' + k))
+			}
+		}
+		return res, nil
+	}
+	res, err := cs.GetOrLoadMany([]int{1, 2, 3, 2, 4}, loader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Fatalf("loader got %v, want only missing keys", got)
+	}
+	if !reflect.DeepEqual(res, map[int]string{1: "one", 2: "c", 3: "d"}) {
+		t.Fatalf("result = %v", res)
+	}
+	res, _ = cs.GetOrLoadMany([]int{1, 2, 3}, loader)
+	if calls != 1 || len(res) != 3 {
+		t.Fatalf("second call should be served from cache, calls = %d res = %v", calls, res)
+	}
+	if s := cs.Stats(); s.Loads != 1 {
+		t.Fatalf("loads = %d, want 1", s.Loads)
+	}
+	fail := errors.New("backend down")
+	res, err = cs.GetOrLoadMany([]int{1, 10}, func([]int) (map[int]string, error) {
+		return nil, fail
+	})
+	if err != fail || !reflect.DeepEqual(res, map[int]string{1: "one"}) {
+		t.Fatalf("res = %v err = %v", res, err)
+	}
+	res, err = cs.GetOrLoadMany([]int{11}, func([]int) (map[int]string, error) {
+		panic("boom")
+	})
+	if err == nil || len(res) != 0 {
+		t.Fatalf("panic should be returned as error, res = %v err = %v", res, err)
+	}
+}
+
+func TestGetOrLoadManyDedup(t *testing.T) {
+	cs := NewCacheStore[int, int](0, 0)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var batches [][]int
+	loader := func(keys []int) (map[int]int, error) {
+		mu.Lock()
+		batches = append(batches, append([]int(nil), keys...))
+		first := len(batches) == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+		res := make(map[int]int)
+		for _, k := range keys {
+			res[k] = k * 10
+		}
+		return res, nil
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		cs.GetOrLoadMany([]int{1, 2}, loader)
+	}()
+	<-started
+
+	//正在加载的键与单键GetOrLoad共享结果
+	single := make(chan int)
+	go func() {
+		v, _ := cs.GetOrLoad(1, func(int) (int, error) { return -1, nil })
+		single <- v
+	}()
+	done := make(chan map[int]int)
+	go func() {
+		res, _ := cs.GetOrLoadMany([]int{2, 3}, loader)
+		done <- res
+	}()
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(batches) == 2
+	})
+	close(release)
+	wg.Wait()
+	if v := <-single; v != 10 {
+		t.Fatalf("GetOrLoad got %d, want 10 from the batch", v)
+	}
+	if res := <-done; !reflect.DeepEqual(res, map[int]int{2: 20, 3: 30}) {
+		t.Fatalf("res = %v", res)
+	}
+	sort.Ints(batches[1])
+	if !reflect.DeepEqual(batches[1], []int{3}) {
+		t.Fatalf("second batch = %v, want [3]", batches[1])
+	}
+}
+
+func TestGetOrLoadManyNegative(t *testing.T) {
+	cs := NewCacheStore(0, 0, WithNegativeTTL[int, int](time.Minute))
+	defer cs.Close()
+	var calls int32
+	loader := func(keys []int) (map[int]int, error) {
+		atomic.AddInt32(&calls, 1)
+		return map[int]int{}, nil
+	}
+	cs.GetOrLoadMany([]int{1}, loader)
+	cs.GetOrLoadMany([]int{1}, loader)
+	if calls != 1 {
+		t.Fatalf("missing key should be negatively cached, calls = %d", calls)
+	}
+	if _, err := cs.GetOrLoad(1, func(int) (int, error) { return 1, nil }); !errors.Is(err, ErrNotLoaded) {
+		t.Fatalf("err = %v, want ErrNotLoaded", err)
+	}
+}
@@ -0,0 +1,138 @@
+package cachestore
+
+import (
+	"encoding/json"
+)
+
+//BusEventType 失效事件类型
+type BusEventType int
+
+const (
+	//BusDelete 删除指定的键
+	BusDelete BusEventType = iota
+	//BusInvalidateTag 删除带有指定标签的元素
+	BusInvalidateTag
+	//BusFlush 清空缓存仓
+	BusFlush
+)
+
+//BusEvent 在进程间传递的失效事件
+//Keys为经过Bus键编码后的字符串
+type BusEvent struct {
+	Type BusEventType `json:"type"`
+	Keys []string     `json:"keys,omitempty"`
+	Tag  string       `json:"tag,omitempty"`
+}
+
+//Transport 失效事件的传输层
+//Publish将事件发送给所有对端，Subscribe设置接收对端事件的处理函数
+//传输层在与对端重新建立连接且对端可能错过事件时，应向对端发送BusFlush
+type Transport interface {
+	Publish(ev BusEvent) error
+	Subscribe(handler func(BusEvent))
+	Close() error
+}
+
+//Bus 缓存失效总线
+//通过Bus执行的写入、删除与标签失效会先作用于本地缓存仓，再通知所有对端删除相应的元素
+type Bus[K comparable, V any] struct {
+	cs        *CacheStore[K, V]
+	transport Transport
+
+	//EncodeKey/DecodeKey 键与字符串的相互转换，默认使用JSON
+	EncodeKey func(K) (string, error)
+	DecodeKey func(string) (K, error)
+	//OnError 接收应用对端事件时发生的错误，可为nil
+	OnError func(error)
+}
+
+//NewBus 将缓存仓接入失效总线
+func NewBus[K comparable, V any](cs *CacheStore[K, V], transport Transport) *Bus[K, V] {
+	b := &Bus[K, V]{
+		cs:        cs,
+		transport: transport,
+		EncodeKey: jsonEncodeKey[K],
+		DecodeKey: jsonDecodeKey[K],
+	}
+	transport.Subscribe(b.apply)
+	return b
+}
+
+//Save 保存一个元素值，并通知对端删除该键的旧值
+func (b *Bus[K, V]) Save(key K, value V) error {
+	b.cs.Save(key, value)
+	return b.publishKeys([]K{key})
+}
+
+//Delete 删除元素，并通知对端删除
+func (b *Bus[K, V]) Delete(keys ...K) error {
+	for _, key := range keys {
+		b.cs.Delete(key)
+	}
+	return b.publishKeys(keys)
+}
+
+//InvalidateTag 删除带有该标签的元素，并通知对端删除
+func (b *Bus[K, V]) InvalidateTag(tag string) error {
+	b.cs.InvalidateTag(tag)
+	return b.transport.Publish(BusEvent{Type: BusInvalidateTag, Tag: tag})
+}
+
+//Flush 清空缓存仓，并通知对端清空
+func (b *Bus[K, V]) Flush() error {
+	b.cs.Clear()
+	return b.transport.Publish(BusEvent{Type: BusFlush})
+}
+
+//Close 关闭传输层，不会关闭缓存仓
+func (b *Bus[K, V]) Close() error {
+	return b.transport.Close()
+}
+
+func (b *Bus[K, V]) publishKeys(keys []K) error {
+	encoded := make([]string, 0, len(keys))
+	for _, key := range keys {
+		s, err := b.EncodeKey(key)
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, s)
+	}
+	return b.transport.Publish(BusEvent{Type: BusDelete, Keys: encoded})
+}
+
+//应用对端发来的事件
+func (b *Bus[K, V]) apply(ev BusEvent) {
+	switch ev.Type {
+	case BusDelete:
+		for _, s := range ev.Keys {
+			key, err := b.DecodeKey(s)
+			if err != nil {
+				b.report(err)
+				continue
+			}
+			b.cs.Delete(key)
+		}
+	case BusInvalidateTag:
+		b.cs.InvalidateTag(ev.Tag)
+	case BusFlush:
+		b.cs.Clear()
+	}
+}
+
+func (b *Bus[K, V]) report(err error) {
+	if b.OnError != nil {
+		b.OnError(err)
+	}
+}
+
+func jsonEncodeKey[K comparable](key K) (string, error) {
+	data, err := json.Marshal(key)
+	return string(data), err
+}
+
+func jsonDecodeKey[K comparable](s string) (K, error) {
+	var key K
+	err := json.Unmarshal([]byte(s), &key)
+	return key, err
+}
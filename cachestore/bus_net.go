@@ -0,0 +1,317 @@
+package cachestore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+//网络传输层的重连与写超时
+const (
+	busMinRetry     = 100 * time.Millisecond
+	busMaxRetry     = 5 * time.Second
+	busWriteTimeout = 5 * time.Second
+)
+
+//ErrTransportClosed 传输层已关闭
+var ErrTransportClosed = errors.New("cachestore: transport closed")
+
+//NetTransport 基于TCP或unix socket的失效事件传输层
+//每个节点监听一个地址接收事件，并主动连接所有对端发送事件，事件以JSON行传输
+//与对端的连接断开后会自动重连；断开期间有事件未能送达时，重连后先向对端发送BusFlush
+type NetTransport struct {
+	network  string
+	listener net.Listener
+
+	lock    sync.Mutex //保护以下字段，不在持有期间进行网络写入
+	handler func(BusEvent)
+	peers   map[string]*busPeer
+	inbound map[net.Conn]struct{}
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+//到一个对端的出站连接
+type busPeer struct {
+	addr string
+	wake chan struct{}
+
+	lock      sync.Mutex //保护conn与needFlush，写入期间持有以保证同一对端的事件顺序
+	conn      net.Conn
+	needFlush bool //对端可能错过了事件，重连后需要清空
+}
+
+//NewTCPTransport 监听listenAddr并连接peers中的对端
+func NewTCPTransport(listenAddr string, peers ...string) (*NetTransport, error) {
+	return newNetTransport("tcp", listenAddr, peers)
+}
+
+//NewUnixTransport 监听unix socket文件socketPath并连接peers中的对端
+//socket文件已存在时会先删除
+func NewUnixTransport(socketPath string, peers ...string) (*NetTransport, error) {
+	os.Remove(socketPath)
+	return newNetTransport("unix", socketPath, peers)
+}
+
+func newNetTransport(network, addr string, peers []string) (*NetTransport, error) {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &NetTransport{
+		network:  network,
+		listener: l,
+		peers:    make(map[string]*busPeer),
+		inbound:  make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.accept()
+	for _, p := range peers {
+		t.AddPeer(p)
+	}
+	return t, nil
+}
+
+//Addr 获取监听地址
+func (t *NetTransport) Addr() net.Addr {
+	return t.listener.Addr()
+}
+
+//AddPeer 添加一个对端，已存在的对端会被忽略
+func (t *NetTransport) AddPeer(addr string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return
+	}
+	if _, ok := t.peers[addr]; ok {
+		return
+	}
+	p := &busPeer{addr: addr, wake: make(chan struct{}, 1)}
+	t.peers[addr] = p
+	t.wg.Add(1)
+	go t.connect(p)
+}
+
+//Subscribe 设置接收对端事件的处理函数
+func (t *NetTransport) Subscribe(handler func(BusEvent)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.handler = handler
+}
+
+//Publish 将事件发送给所有已连接的对端
+//未连接或发送失败的对端会在重连后收到BusFlush，因此不返回单个对端的错误
+//写入在传输层的锁之外进行，缓慢的对端不会阻塞事件的接收
+func (t *NetTransport) Publish(ev BusEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return ErrTransportClosed
+	}
+	peers := t.peerList()
+	t.lock.Unlock()
+	for _, p := range peers {
+		p.send(data)
+	}
+	return nil
+}
+
+//获取所有对端 调用方需持有锁
+func (t *NetTransport) peerList() []*busPeer {
+	peers := make([]*busPeer, 0, len(t.peers))
+	for _, p := range t.peers {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+//向对端写入数据
+func (p *busPeer) send(data []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.write(data)
+}
+
+//向对端写入数据，失败时断开连接等待重连 调用方需持有p.lock
+func (p *busPeer) write(data []byte) {
+	if p.conn == nil {
+		p.needFlush = true
+		return
+	}
+	p.conn.SetWriteDeadline(time.Now().Add(busWriteTimeout))
+	if _, err := p.conn.Write(data); err != nil {
+		p.conn.Close()
+		p.conn = nil
+		p.needFlush = true
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//Close 关闭监听与所有连接，并等待后台协程退出
+func (t *NetTransport) Close() error {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	err := t.listener.Close()
+	for c := range t.inbound {
+		c.Close()
+	}
+	peers := t.peerList()
+	t.lock.Unlock()
+	for _, p := range peers {
+		p.lock.Lock()
+		if p.conn != nil {
+			p.conn.Close()
+			p.conn = nil
+		}
+		p.lock.Unlock()
+	}
+	t.wg.Wait()
+	return err
+}
+
+//维持到对端的连接 断开后按指数退避重连
+func (t *NetTransport) connect(p *busPeer) {
+	defer t.wg.Done()
+	retry := busMinRetry
+	for {
+		conn, err := net.DialTimeout(t.network, p.addr, busWriteTimeout)
+		if err == nil {
+			t.lock.Lock()
+			closed := t.closed
+			t.lock.Unlock()
+			if closed {
+				conn.Close()
+				return
+			}
+			//此后关闭传输层时由watch关闭连接
+			p.lock.Lock()
+			p.conn = conn
+			//丢弃上一个连接遗留的唤醒信号
+			select {
+			case <-p.wake:
+			default:
+			}
+			if p.needFlush {
+				p.needFlush = false
+				data, _ := json.Marshal(BusEvent{Type: BusFlush})
+				p.write(append(data, '\n'))
+			}
+			p.lock.Unlock()
+			retry = busMinRetry
+			//对端不会在出站连接上发送数据，读取只用于发现连接断开
+			t.watch(p, conn)
+		}
+		select {
+		case <-t.done:
+			return
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > busMaxRetry {
+			retry = busMaxRetry
+		}
+	}
+}
+
+//阻塞直到出站连接断开
+func (t *NetTransport) watch(p *busPeer, conn net.Conn) {
+	closed := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+	select {
+	case <-closed:
+	case <-p.wake:
+	case <-t.done:
+	}
+	//先关闭连接，使阻塞在写入上的发送方立即返回并释放p.lock
+	conn.Close()
+	p.lock.Lock()
+	if p.conn == conn {
+		p.conn = nil
+		//连接断开时可能有已写出但未送达的事件
+		p.needFlush = true
+	}
+	p.lock.Unlock()
+}
+
+//接受对端的入站连接
+func (t *NetTransport) accept() {
+	defer t.wg.Done()
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(busMinRetry)
+				continue
+			}
+			return
+		}
+		t.lock.Lock()
+		if t.closed {
+			t.lock.Unlock()
+			conn.Close()
+			return
+		}
+		t.inbound[conn] = struct{}{}
+		t.wg.Add(1)
+		t.lock.Unlock()
+		go t.receive(conn)
+	}
+}
+
+//读取入站连接上的事件并交给处理函数
+func (t *NetTransport) receive(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		t.lock.Lock()
+		delete(t.inbound, conn)
+		t.lock.Unlock()
+		t.wg.Done()
+	}()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64<<10), 64<<20)
+	for scanner.Scan() {
+		var ev BusEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		t.lock.Lock()
+		handler := t.handler
+		t.lock.Unlock()
+		if handler != nil {
+			handler(ev)
+		}
+	}
+}
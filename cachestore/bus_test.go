@@ -0,0 +1,183 @@
+package cachestore
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//等待条件成立，用于等待对端异步应用事件
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+//所有对端的出站连接是否都已建立
+func connected(t *NetTransport) bool {
+	t.lock.Lock()
+	peers := t.peerList()
+	t.lock.Unlock()
+	for _, p := range peers {
+		p.lock.Lock()
+		conn := p.conn
+		p.lock.Unlock()
+		if conn == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBusTCP(t *testing.T) {
+	ta, err := NewTCPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, err := NewTCPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrB := tb.Addr().String()
+	ta.AddPeer(addrB)
+	tb.AddPeer(ta.Addr().String())
+
+	csA := NewCacheStore[string, int](10, 0)
+	csB := NewCacheStore[string, int](10, 0)
+	busA := NewBus(csA, ta)
+	defer busA.Close()
+	busB := NewBus(csB, tb)
+
+	for _, cs := range []*CacheStore[string, int]{csA, csB} {
+		cs.Save("user:1", 1)
+		cs.SaveTagged("list:1", 1, "user:1")
+		cs.Save("other", 1)
+	}
+	//等待双方的出站连接建立
+	eventually(t, func() bool { return connected(ta) && connected(tb) })
+
+	busA.Save("user:1", 2)
+	eventually(t, func() bool {
+		_, ok := csB.Peek("user:1")
+		return !ok
+	})
+	busB.InvalidateTag("user:1")
+	eventually(t, func() bool {
+		_, ok := csA.Peek("list:1")
+		return !ok
+	})
+	if v, _ := csA.Peek("user:1"); v != 2 {
+		t.Error("publisher should keep its own write")
+	}
+
+	//B离线期间A发布的事件无法送达，B重新加入后应被清空
+	busB.Close()
+	busA.Delete("nothing")
+	tb2, err := NewTCPTransport(addrB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	busB2 := NewBus(csB, tb2)
+	defer busB2.Close()
+	eventually(t, func() bool { return csB.Len() == 0 })
+	if csA.Len() == 0 {
+		t.Error("rejoin flush should only clear the rejoining peer")
+	}
+}
+
+func TestBusSlowPeer(t *testing.T) {
+	//只接受连接而从不读取的对端，写满缓冲区后写入会阻塞
+	stalled, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stalled.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := stalled.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	ta, err := NewTCPTransport("127.0.0.1:0", stalled.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ta.Close()
+	tb, err := NewTCPTransport("127.0.0.1:0", ta.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	received := make(chan BusEvent, 1)
+	ta.Subscribe(func(ev BusEvent) {
+		select {
+		case received <- ev:
+		default:
+		}
+	})
+	eventually(t, func() bool { return connected(ta) && connected(tb) })
+
+	big := BusEvent{Type: BusDelete, Keys: []string{strings.Repeat("x", 1<<20)}}
+	go func() {
+		for i := 0; i < 64; i++ {
+			if ta.Publish(big) == ErrTransportClosed {
+				return
+			}
+		}
+	}()
+	time.Sleep(200 * time.Millisecond)
+	//向缓慢对端的写入不应阻塞事件的接收
+	tb.Publish(BusEvent{Type: BusFlush})
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("receive blocked by a slow peer")
+	}
+}
+
+func TestBusUnix(t *testing.T) {
+	dir := t.TempDir()
+	pathA := filepath.Join(dir, "a.sock")
+	pathB := filepath.Join(dir, "b.sock")
+	ta, err := NewUnixTransport(pathA, pathB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tb, err := NewUnixTransport(pathB, pathA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csA := NewCacheStore[int, string](10, 0)
+	csB := NewCacheStore[int, string](10, 0)
+	busA := NewBus(csA, ta)
+	defer busA.Close()
+	busB := NewBus(csB, tb)
+	defer busB.Close()
+	csB.Save(42, "stale")
+	csB.Save(7, "keep")
+	//A在B的socket就绪前发布时会在连接后先发送清空事件，两种情况下42都会被删除
+	eventually(t, func() bool {
+		busA.Delete(42)
+		_, ok := csB.Peek(42)
+		return !ok
+	})
+	busA.Flush()
+	eventually(t, func() bool { return csB.Len() == 0 })
+}
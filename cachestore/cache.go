@@ -1,27 +1,83 @@
 package cachestore
 
 import (
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 //CacheStore 缓存仓
 type CacheStore[K comparable, V any] struct {
+	stats    *statsCounter
 	data     map[K]*cacheItem[K, V]
-	gcs      []*cacheItem[K, V]
+	expires  expireHeap[K, V]
+	policy   EvictionPolicy[K]
 	cap      int
 	lifetime time.Duration
+	sliding  bool
 	lock     sync.Mutex
+	clock    Clock
+	hasher   func(K) uint64
+
+	onEvict   []func(key K, value V, reason EvictReason)
+	pending   []evictEvent[K, V]
+	evictHook func(key K, value V, reason EvictReason) //在锁内同步调用，不能操作缓存仓
+
+	ageBase time.Time //计算写入时间的基准，创建时取当前时间
+	ageSum  float64   //所有元素写入时间相对ageBase的纳秒数之和，用于计算平均存活时长
+
+	tags map[string]map[K]struct{} //标签 -> 带有该标签的键
+
+	costFn    func(K, V) int64
+	maxCost   int64
+	totalCost int64
+
+	gcOnce  sync.Once
+	workers sync.WaitGroup //后台协程，关闭时等待其退出
+	done    chan struct{}
+	closed  bool
+
+	codec    Codec
+	snapshot *snapshotConfig
+
+	loading   map[K]*loadCall[V]
+	negatives map[K]*negativeItem
+	negQueue  []negativeEntry[K]
+	negTTL    time.Duration
+
+	refreshConf *refreshConfig
+
+	admission AdmissionPolicy
 }
 
 type cacheItem[K comparable, V any] struct {
-	key    K
-	value  V
-	gcTime time.Time
+	key     K
+	value   V
+	ttl     time.Duration
+	gcTime  time.Time
+	created time.Time
+	cost    int64
+	tags    []string
+	loaded  bool          //是否由GetOrLoad加载
+	grace   time.Duration //过期后仍可作为旧值返回的时长
+	index   int           //在过期堆中的位置，不在堆中时为-1
+}
+
+//是否已过期 未设置存活时间的元素永不过期
+func (item *cacheItem[K, V]) expired(now time.Time) bool {
+	return item.ttl > 0 && !now.Before(item.gcTime)
+}
+
+//回收时间 过期时间加上旧值容忍期
+func (item *cacheItem[K, V]) deadline() time.Time {
+	return item.gcTime.Add(item.grace)
 }
 
 //Len 获取缓存仓当前存储量
 func (cs *CacheStore[K, V]) Len() int {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
 	return len(cs.data)
 }
 
@@ -30,88 +86,302 @@ func (cs *CacheStore[K, V]) Cap() int {
 	return cs.cap
 }
 
+//Cost 获取当前所有元素的成本之和，未设置成本函数时为0
+func (cs *CacheStore[K, V]) Cost() int64 {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	return cs.totalCost
+}
+
+//MaxCost 获取成本预算，0表示不限制
+func (cs *CacheStore[K, V]) MaxCost() int64 {
+	return cs.maxCost
+}
+
 //Save 保存一个元素值
+//键已存在时覆盖原值并重置过期时间
 func (cs *CacheStore[K, V]) Save(key K, value V) bool {
+	return cs.SaveWithTTL(key, value, cs.lifetime)
+}
 
+//SaveWithTTL 以单独的存活时间保存一个元素值
+//ttl小于等于0时该元素永不过期
+//设置了成本预算时，单个元素的成本超出预算将不会被保存并返回false
+//设置了准入策略时，缓存仓已满且新键未被接受也会返回false
+func (cs *CacheStore[K, V]) SaveWithTTL(key K, value V, ttl time.Duration) bool {
+	if ttl < 0 {
+		ttl = 0
+	}
+	if ttl > 0 {
+		cs.startGC()
+	}
 	cs.lock.Lock()
-	cs.toCheckOrGcFirstItem()
-	item := &cacheItem[K, V]{
+	defer cs.unlock()
+	cs.cancelLoad(key)
+	return cs.saveItem(key, value, ttl, cs.clock.Now())
+}
+
+//写入一个元素 调用方需持有锁
+func (cs *CacheStore[K, V]) saveItem(key K, value V, ttl time.Duration, now time.Time) bool {
+	delete(cs.negatives, key)
+	var cost int64
+	if cs.costFn != nil {
+		cost = cs.costFn(key, value)
+		if cs.maxCost > 0 && cost > cs.maxCost {
+			//新值无法保存，旧值也不应继续被读取
+			cs.removeItem(key, EvictReplaced)
+			return false
+		}
+	}
+	cs.record(key)
+	var tags []string
+	item, resident := cs.data[key]
+	if resident {
+		reason := EvictReplaced
+		if item.expired(now) {
+			reason = EvictExpired
+		}
+		if cs.maxCost > 0 && cs.totalCost-item.cost+cost > cs.maxCost {
+			//成本增加超出预算，移除旧值后按新元素写入，以便淘汰其他元素腾出空间
+			tags = item.tags
+			cs.removeItem(key, reason)
+		} else {
+			cs.evicted(key, item.value, reason)
+			item.value = value
+			item.ttl = ttl
+			cs.totalCost += cost - item.cost
+			item.cost = cost
+			item.loaded = false
+			item.grace = 0
+			cs.setCreated(item, now)
+			cs.touchItem(item, now)
+			cs.policy.Access(key)
+			return true
+		}
+	}
+	if !resident && !cs.admit(key, cost) {
+		atomic.AddUint64(&cs.stats.rejections, 1)
+		return false
+	}
+	cs.toCheckOrGcFirstItem(cost)
+	item = &cacheItem[K, V]{
 		key:   key,
 		value: value,
+		ttl:   ttl,
+		cost:  cost,
+		index: -1,
 	}
-	if cs.lifetime > 0 {
-		item.gcTime = time.Now().Add(cs.lifetime)
-	}
+	cs.setCreated(item, now)
+	cs.touchItem(item, now)
 	cs.data[key] = item
-	cs.gcs = append(cs.gcs, item)
-	cs.lock.Unlock()
+	cs.totalCost += cost
+	cs.policy.Add(key)
+	cs.setTags(item, tags)
 	return true
 }
 
 //Get  获取一个元素值
+//开启滑动过期时，成功读取会延长元素的过期时间
 func (cs *CacheStore[K, V]) Get(key K) V {
-	var res V
 	cs.lock.Lock()
 	defer cs.lock.Unlock()
+	res, _ := cs.getItem(key, cs.clock.Now())
+	return res
+}
+
+//读取一个未过期的元素 调用方需持有锁
+func (cs *CacheStore[K, V]) getItem(key K, now time.Time) (V, bool) {
+	var res V
 	if v, ok := cs.data[key]; ok {
-		if cs.lifetime == 0 || time.Now().Before(v.gcTime) {
-			return v.value
+		if !v.expired(now) {
+			cs.record(key)
+			if cs.sliding {
+				cs.touchItem(v, now)
+			}
+			cs.policy.Access(key)
+			atomic.AddUint64(&cs.stats.hits, 1)
+			return v.value, true
 		}
 	}
-	return res
+	atomic.AddUint64(&cs.stats.misses, 1)
+	return res, false
 }
 
-//容量检测，去除最早的元素
-func (cs *CacheStore[K, V]) toCheckOrGcFirstItem() {
-	if len(cs.data) >= cs.cap {
-		item := cs.gcs[0]
-		if item != nil {
-			delete(cs.data, item.key)
-			cs.gcs = cs.gcs[1:]
-		}
+//向准入策略记录一次访问 命中与写入各计一次，未命中后写入的键不会重复计数 调用方需持有锁
+func (cs *CacheStore[K, V]) record(key K) {
+	if cs.admission != nil {
+		cs.admission.Record(cs.hasher(key))
 	}
 }
 
-//过期回收
-func (cs *CacheStore[K, V]) gc() {
-	for {
-		<-time.Tick(1 * time.Second)
-		cs.doGC()
+//判断新键能否写入 需要淘汰元素时由准入策略与淘汰策略选出的键比较 调用方需持有锁
+func (cs *CacheStore[K, V]) admit(key K, cost int64) bool {
+	if cs.admission == nil {
+		return true
 	}
+	if !cs.full(cost) {
+		return true
+	}
+	victim, ok := cs.policy.Victim()
+	if !ok {
+		return true
+	}
+	return cs.admission.Admit(cs.hasher(key), cs.hasher(victim))
 }
 
-//单独进行一次回收处理
-func (cs *CacheStore[K, V]) doGC() {
-	grayKey := make(map[int]bool, 10)
-	for k, item := range cs.gcs {
-		if item != nil && time.Now().After(item.gcTime) {
-			cs.lock.Lock()
-			delete(cs.data, item.key)
-			cs.lock.Unlock()
-			grayKey[k] = true
+//是否需要淘汰元素才能容纳cost 调用方需持有锁
+func (cs *CacheStore[K, V]) full(cost int64) bool {
+	return (cs.cap > 0 && len(cs.data) >= cs.cap) || (cs.maxCost > 0 && cs.totalCost+cost > cs.maxCost)
+}
+
+//容量检测，按淘汰策略去除元素直到有空余位置且剩余预算可容纳cost
+func (cs *CacheStore[K, V]) toCheckOrGcFirstItem(cost int64) {
+	for cs.full(cost) {
+		key, ok := cs.policy.Victim()
+		if !ok {
+			return
 		}
+		cs.removeItem(key, EvictCapacity)
+	}
+}
+
+//从数据和淘汰策略中移除一个键 调用方需持有锁
+//主动删除时同时取消该键正在进行的加载，避免加载结果在删除后被写回
+func (cs *CacheStore[K, V]) removeItem(key K, reason EvictReason) bool {
+	if reason == EvictDeleted {
+		cs.cancelLoad(key)
+	}
+	item, ok := cs.data[key]
+	if !ok {
+		return false
+	}
+	cs.unscheduleItem(item)
+	delete(cs.data, key)
+	cs.policy.Remove(key)
+	cs.untagItem(item)
+	cs.totalCost -= item.cost
+	cs.ageSum -= cs.ageOffset(item.created)
+	switch reason {
+	case EvictCapacity:
+		atomic.AddUint64(&cs.stats.evictions, 1)
+	case EvictExpired:
+		atomic.AddUint64(&cs.stats.expirations, 1)
 	}
+	cs.evicted(key, item.value, reason)
+	return true
+}
+
+//Delete 删除一个元素，返回元素是否存在
+func (cs *CacheStore[K, V]) Delete(key K) bool {
 	cs.lock.Lock()
-	newGcs := make([]*cacheItem[K, V], 0, cap(cs.gcs))
-	for k, item := range cs.gcs {
-		if _, ok := grayKey[k]; !ok {
-			newGcs = append(newGcs, item)
+	defer cs.unlock()
+	delete(cs.negatives, key)
+	return cs.removeItem(key, EvictDeleted)
+}
+
+//启动过期回收 只会启动一次
+func (cs *CacheStore[K, V]) startGC() {
+	cs.gcOnce.Do(func() {
+		cs.lock.Lock()
+		defer cs.lock.Unlock()
+		if cs.closed {
+			return
+		}
+		cs.workers.Add(1)
+		go cs.gc(cs.clock.NewTicker(1 * time.Second))
+	})
+}
+
+//过期回收 缓存仓关闭后退出
+func (cs *CacheStore[K, V]) gc(ticker Ticker) {
+	defer cs.workers.Done()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			cs.doGC()
+		case <-cs.done:
+			return
 		}
 	}
-	cs.gcs = newGcs
+}
+
+//Close 关闭缓存仓，停止后台协程并等待其退出
+//配置了定期快照时，退出前会写入最后一次快照
+//关闭后缓存仓仍可读写，过期元素只在读取时判定
+func (cs *CacheStore[K, V]) Close() error {
+	cs.lock.Lock()
+	if !cs.closed {
+		cs.closed = true
+		close(cs.done)
+	}
 	cs.lock.Unlock()
+	cs.workers.Wait()
+	return nil
+}
+
+//单独进行一次回收处理
+//只处理过期堆顶已到期的元素，不遍历整个缓存仓
+func (cs *CacheStore[K, V]) doGC() {
+	cs.lock.Lock()
+	defer cs.unlock()
+	now := cs.clock.Now()
+	cs.expireItems(now)
+	cs.expireNegatives(now)
 }
 
 //NewCacheStore 获取一个新的缓存仓
-func NewCacheStore[K comparable, V any](cap int, lifetime time.Duration) *CacheStore[K, V] {
+//cap小于等于0时不限制容量
+func NewCacheStore[K comparable, V any](cap int, lifetime time.Duration, opts ...Option[K, V]) *CacheStore[K, V] {
+	size := cap
+	if size < 0 {
+		size = 0
+	}
 	cs := &CacheStore[K, V]{
-		data:     make(map[K]*cacheItem[K, V], cap),
-		gcs:      make([]*cacheItem[K, V], 0, cap),
+		stats:    &statsCounter{},
+		data:     make(map[K]*cacheItem[K, V], size),
 		cap:      cap,
 		lifetime: lifetime,
+		loading:  make(map[K]*loadCall[V]),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(cs)
 	}
-	if lifetime > 0 {
-		go cs.gc()
+	if cs.hasher == nil {
+		cs.hasher = defaultHash[K]
 	}
+	if cs.clock == nil {
+		cs.clock = realClock{}
+	}
+	cs.ageBase = cs.clock.Now()
+	if cs.policy == nil {
+		cs.policy = NewFIFOPolicy[K]()
+	}
+	if cs.negTTL > 0 {
+		cs.negatives = make(map[K]*negativeItem)
+	}
+	if cs.codec == nil {
+		cs.codec = GobCodec
+	}
+	if lifetime > 0 || cs.negTTL > 0 {
+		cs.startGC()
+	}
+	if cs.snapshot != nil {
+		cs.startSnapshot()
+	}
+	return cs
+}
+
+//NewCacheStoreWithContext 获取一个随ctx结束而关闭的缓存仓
+func NewCacheStoreWithContext[K comparable, V any](ctx context.Context, cap int, lifetime time.Duration, opts ...Option[K, V]) *CacheStore[K, V] {
+	cs := NewCacheStore(cap, lifetime, opts...)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cs.Close()
+		case <-cs.done:
+		}
+	}()
 	return cs
 }
@@ -3,6 +3,7 @@ package cachestore
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 func TestCacheStore(t *testing.T) {
@@ -45,3 +46,36 @@ func TestCacheStoreStruct(t *testing.T) {
 	t.Logf("%p\n", &a)
 
 }
+
+func TestSaveWithTTL(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 0, WithClock[string, string](clock))
+	defer cs.Close()
+	cs.SaveWithTTL("short", "a", 2*time.Second)
+	cs.SaveWithTTL("long", "b", time.Hour)
+	cs.Save("forever", "c")
+	clock.Advance(3 * time.Second)
+	if cs.Get("short") != "" {
+		t.Error("short entry should be expired")
+	}
+	if cs.Get("long") != "b" || cs.Get("forever") != "c" {
+		t.Error("long lived entries should still be readable")
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 3*time.Second, WithSlidingExpiration[string, string](), WithClock[string, string](clock))
+	defer cs.Close()
+	cs.Save("idle", "a")
+	cs.Save("busy", "b")
+	for i := 0; i < 4; i++ {
+		clock.Advance(2 * time.Second)
+		if cs.Get("busy") != "b" {
+			t.Fatal("busy entry should be kept alive by Get")
+		}
+	}
+	if cs.Get("idle") != "" {
+		t.Error("idle entry should be expired")
+	}
+}
@@ -0,0 +1,123 @@
+package cachestore
+
+import (
+	"sync"
+	"time"
+)
+
+//Clock 时钟接口
+//缓存仓通过时钟获取当前时间并驱动过期回收，测试中可替换为ManualClock
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+//Ticker 定时触发器接口
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+//系统时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (rt realTicker) C() <-chan time.Time {
+	return rt.t.C
+}
+
+func (rt realTicker) Stop() {
+	rt.t.Stop()
+}
+
+//ManualClock 手动推进的时钟
+//时间只在调用Advance/Set时变化，用于确定性地测试过期逻辑
+type ManualClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+//NewManualClock 以指定时间创建一个手动时钟
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+//Now 返回当前时间
+func (mc *ManualClock) Now() time.Time {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return mc.now
+}
+
+//NewTicker 创建一个随手动时间触发的定时器
+func (mc *ManualClock) NewTicker(d time.Duration) Ticker {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	mt := &manualTicker{
+		c:      make(chan time.Time),
+		stop:   make(chan struct{}),
+		period: d,
+		next:   mc.now.Add(d),
+	}
+	mc.tickers = append(mc.tickers, mt)
+	return mt
+}
+
+//Advance 将时间推进d
+//到期的定时器会被触发，Advance会阻塞直到接收方收到触发信号
+func (mc *ManualClock) Advance(d time.Duration) {
+	mc.Set(mc.Now().Add(d))
+}
+
+//Set 将时间设置为now
+func (mc *ManualClock) Set(now time.Time) {
+	mc.lock.Lock()
+	mc.now = now
+	var fire []*manualTicker
+	for _, mt := range mc.tickers {
+		if !now.Before(mt.next) {
+			//与time.Ticker一致，落后的多次触发合并为一次
+			for !now.Before(mt.next) {
+				mt.next = mt.next.Add(mt.period)
+			}
+			fire = append(fire, mt)
+		}
+	}
+	mc.lock.Unlock()
+	for _, mt := range fire {
+		select {
+		case mt.c <- now:
+		case <-mt.stop:
+		}
+	}
+}
+
+type manualTicker struct {
+	c        chan time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+	period   time.Duration
+	next     time.Time
+}
+
+func (mt *manualTicker) C() <-chan time.Time {
+	return mt.c
+}
+
+func (mt *manualTicker) Stop() {
+	mt.stopOnce.Do(func() {
+		close(mt.stop)
+	})
+}
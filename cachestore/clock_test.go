@@ -0,0 +1,61 @@
+package cachestore
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+//等待后台回收协程处理完触发信号
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		runtime.Gosched()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManualClockDrivesGC(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 5*time.Second, WithClock[string, int](clock))
+	defer cs.Close()
+	cs.Save("a", 1)
+	cs.SaveWithTTL("b", 2, time.Minute)
+	clock.Advance(6 * time.Second)
+	waitFor(t, func() bool { return cs.Len() == 1 })
+	if cs.Get("b") != 2 {
+		t.Error("b should not be collected")
+	}
+}
+
+func TestClose(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, time.Second, WithClock[string, int](clock))
+	cs.Save("a", 1)
+	cs.Close()
+	cs.Close()
+	//回收协程已退出，推进时钟不应阻塞，也不应回收元素
+	clock.Advance(2 * time.Second)
+	if cs.Len() != 1 {
+		t.Error("closed store should not run gc")
+	}
+	if cs.Get("a") != 0 {
+		t.Error("expired entry should not be readable after close")
+	}
+}
+
+func TestNewCacheStoreWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cs := NewCacheStoreWithContext[string, int](ctx, 10, time.Second)
+	cancel()
+	select {
+	case <-cs.done:
+	case <-time.After(time.Second):
+		t.Error("store should be closed when ctx is done")
+	}
+}
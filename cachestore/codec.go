@@ -0,0 +1,50 @@
+package cachestore
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+)
+
+//Codec 序列化编解码器，用于快照等需要将元素写出的场景
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+//Encoder 将值依次写入流
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+//Decoder 从流中依次读取值
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+//内置编解码器
+//使用gob时，值中包含的接口类型需要事先通过gob.Register注册
+var (
+	GobCodec  Codec = gobCodec{}
+	JSONCodec Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
@@ -0,0 +1,66 @@
+package cachestore
+
+import (
+	"strings"
+	"testing"
+)
+
+func byteCost(key string, value []byte) int64 {
+	return int64(len(value))
+}
+
+func TestCostBudget(t *testing.T) {
+	cs := NewCacheStore(0, 0, WithCost(byteCost, 100))
+	for _, key := range []string{"a", "b", "c"} {
+		cs.Save(key, make([]byte, 30))
+	}
+	if cs.Cost() != 90 {
+		t.Errorf("cost = %d, want 90", cs.Cost())
+	}
+	//写入50字节需要淘汰a、b
+	cs.Save("big", make([]byte, 50))
+	if cs.Cost() != 80 || cs.Len() != 2 {
+		t.Errorf("cost = %d len = %d", cs.Cost(), cs.Len())
+	}
+	if cs.Get("a") != nil || cs.Get("b") != nil || cs.Get("c") == nil {
+		t.Error("oldest entries should be evicted to fit the budget")
+	}
+	if cs.Stats().Cost != 80 || cs.Stats().MaxCost != 100 {
+		t.Errorf("stats = %+v", cs.Stats())
+	}
+}
+
+func TestCostOversized(t *testing.T) {
+	cs := NewCacheStore(0, 0, WithCost(byteCost, 100))
+	cs.Save("page", []byte("small"))
+	if cs.Save("page", make([]byte, 101)) {
+		t.Error("entry larger than the budget should be rejected")
+	}
+	if cs.Get("page") != nil || cs.Cost() != 0 {
+		t.Error("rejected save should drop the old value")
+	}
+}
+
+func TestCostGrowInPlace(t *testing.T) {
+	cs := NewCacheStore(0, 0, WithLRU[string, []byte](), WithCost(byteCost, 100))
+	cs.Save("a", make([]byte, 40))
+	cs.Save("b", make([]byte, 40))
+	cs.Save("a", make([]byte, 70))
+	if cs.Get("b") != nil || len(cs.Get("a")) != 70 || cs.Cost() != 70 {
+		t.Errorf("growing a should evict b, cost = %d", cs.Cost())
+	}
+	cs.Save("a", []byte(strings.Repeat("x", 10)))
+	if cs.Cost() != 10 {
+		t.Errorf("cost = %d, want 10", cs.Cost())
+	}
+}
+
+func TestCostWithCap(t *testing.T) {
+	cs := NewCacheStore(2, 0, WithCost(byteCost, 0))
+	cs.Save("a", make([]byte, 10))
+	cs.Save("b", make([]byte, 20))
+	cs.Save("c", make([]byte, 30))
+	if cs.Len() != 2 || cs.Cost() != 50 {
+		t.Errorf("len = %d cost = %d", cs.Len(), cs.Cost())
+	}
+}
@@ -0,0 +1,95 @@
+package cachestore
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+//元素文件的扩展名
+const dirWriterExt = ".val"
+
+//DirWriter 以目录保存元素的后端存储，每个键对应一个文件
+//文件名为键的SHA-256，值通过编解码器序列化，写入先写临时文件再重命名，不会留下写了一半的文件
+type DirWriter[K comparable, V any] struct {
+	dir   string
+	codec Codec
+}
+
+//NewDirWriter 创建以dir为目录的后端存储，codec为nil时使用GobCodec
+func NewDirWriter[K comparable, V any](dir string, codec Codec) (*DirWriter[K, V], error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if codec == nil {
+		codec = GobCodec
+	}
+	return &DirWriter[K, V]{dir: dir, codec: codec}, nil
+}
+
+//Write 按顺序执行一批写操作，遇到错误时立即返回
+//重复执行同一批操作的结果不变，可以安全地重试
+func (dw *DirWriter[K, V]) Write(ops []WriteOp[K, V]) error {
+	for _, op := range ops {
+		var err error
+		if op.Delete {
+			err = dw.remove(op.Key)
+		} else {
+			err = dw.put(op.Key, op.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Load 读取一个键的值，键不存在时返回os.ErrNotExist
+//可作为GetOrLoad的loader使用
+func (dw *DirWriter[K, V]) Load(key K) (V, error) {
+	var res V
+	file, err := os.Open(dw.path(key))
+	if err != nil {
+		return res, err
+	}
+	defer file.Close()
+	err = dw.codec.NewDecoder(bufio.NewReader(file)).Decode(&res)
+	return res, err
+}
+
+func (dw *DirWriter[K, V]) put(key K, value V) error {
+	path := dw.path(key)
+	tmp, err := os.CreateTemp(dw.dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if err = dw.codec.NewEncoder(w).Encode(value); err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (dw *DirWriter[K, V]) remove(key K) error {
+	if err := os.Remove(dw.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (dw *DirWriter[K, V]) path(key K) string {
+	sum := sha256.Sum256([]byte(diskKey(key)))
+	return filepath.Join(dw.dir, hex.EncodeToString(sum[:])+dirWriterExt)
+}
@@ -0,0 +1,338 @@
+package cachestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//磁盘段文件
+//每条记录的格式为：
+//crc32(4) | flags(1) | expireAt(8) | keyLen(4) | valueLen(4) | key | value
+//crc32覆盖crc之后的全部内容，expireAt为UnixNano，0表示永不过期
+const (
+	diskHeaderSize  = 21
+	diskSegmentExt  = ".seg"
+	diskFlagPut     = 0
+	diskFlagDelete  = 1
+	diskMaxKeyLen   = 1 << 16
+	diskMinSegment  = 1 << 20
+	diskSegmentPart = 8 //未指定段大小时，段大小为预算的1/8
+)
+
+//ErrDiskStoreClosed 磁盘仓已关闭
+var ErrDiskStoreClosed = errors.New("cachestore: disk store closed")
+
+//DiskStore 基于目录的磁盘缓存仓
+//数据以只追加的段文件保存，内存中维护键到记录位置的索引，打开时扫描段文件重建索引
+//总字节数超出预算时整段淘汰最早的段文件
+type DiskStore struct {
+	dir         string
+	maxBytes    int64
+	segmentSize int64
+	lock        sync.Mutex
+	index       map[string]diskEntry
+	segments    []*diskSegment //按写入顺序排列，最后一个为当前写入段
+	size        int64
+	closed      bool
+}
+
+//索引项 记录所在的段与位置
+type diskEntry struct {
+	segment  *diskSegment
+	offset   int64
+	length   int64
+	expireAt int64
+}
+
+type diskSegment struct {
+	id   uint64
+	file *os.File
+	size int64
+}
+
+//NewDiskStore 打开或创建一个磁盘缓存仓
+//maxBytes为段文件总字节数的预算，小于等于0时不限制
+//segmentSize可选，为单个段文件的大小，默认为预算的1/8且不小于1MB；段大小不会超过预算
+func NewDiskStore(dir string, maxBytes int64, segmentSize ...int64) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ds := &DiskStore{
+		dir:      dir,
+		maxBytes: maxBytes,
+		index:    make(map[string]diskEntry),
+	}
+	if len(segmentSize) > 0 && segmentSize[0] > 0 {
+		ds.segmentSize = segmentSize[0]
+	} else {
+		ds.segmentSize = maxBytes / diskSegmentPart
+		if ds.segmentSize < diskMinSegment {
+			ds.segmentSize = diskMinSegment
+		}
+	}
+	//当前写入段不会被淘汰，段大小超出预算时总字节数无法被限制
+	if maxBytes > 0 && ds.segmentSize > maxBytes {
+		ds.segmentSize = maxBytes
+	}
+	if err := ds.open(); err != nil {
+		ds.Close()
+		return nil, err
+	}
+	return ds, nil
+}
+
+//扫描目录下的段文件并重建索引
+func (ds *DiskStore) open() error {
+	names, err := filepath.Glob(filepath.Join(ds.dir, "*"+diskSegmentExt))
+	if err != nil {
+		return err
+	}
+	var ids []uint64
+	for _, name := range names {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(name), diskSegmentExt), 16, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		file, err := os.OpenFile(ds.segmentPath(id), os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+		seg := &diskSegment{id: id, file: file}
+		ds.segments = append(ds.segments, seg)
+		if err := ds.scan(seg); err != nil {
+			return err
+		}
+		ds.size += seg.size
+	}
+	if len(ds.segments) == 0 {
+		return ds.rotate()
+	}
+	return ds.evict()
+}
+
+//顺序读取段内的记录更新索引
+//遇到不完整或校验失败的记录时截断其后的内容
+func (ds *DiskStore) scan(seg *diskSegment) error {
+	info, err := seg.file.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	var offset int64
+	header := make([]byte, diskHeaderSize)
+	for offset+diskHeaderSize <= end {
+		if _, err := seg.file.ReadAt(header, offset); err != nil {
+			break
+		}
+		keyLen := int64(binary.BigEndian.Uint32(header[13:17]))
+		valueLen := int64(binary.BigEndian.Uint32(header[17:21]))
+		length := diskHeaderSize + keyLen + valueLen
+		if keyLen > diskMaxKeyLen || offset+length > end {
+			break
+		}
+		record := make([]byte, length)
+		if _, err := seg.file.ReadAt(record, offset); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(record[4:]) != binary.BigEndian.Uint32(record[0:4]) {
+			break
+		}
+		key := string(record[diskHeaderSize : diskHeaderSize+keyLen])
+		if record[4] == diskFlagDelete {
+			delete(ds.index, key)
+		} else {
+			ds.index[key] = diskEntry{
+				segment:  seg,
+				offset:   offset,
+				length:   length,
+				expireAt: int64(binary.BigEndian.Uint64(record[5:13])),
+			}
+		}
+		offset += length
+	}
+	seg.size = offset
+	if offset < end {
+		return seg.file.Truncate(offset)
+	}
+	return nil
+}
+
+func (ds *DiskStore) segmentPath(id uint64) string {
+	return filepath.Join(ds.dir, fmt.Sprintf("%016x%s", id, diskSegmentExt))
+}
+
+//创建新的写入段 调用方需持有锁
+func (ds *DiskStore) rotate() error {
+	var id uint64 = 1
+	if n := len(ds.segments); n > 0 {
+		id = ds.segments[n-1].id + 1
+	}
+	file, err := os.OpenFile(ds.segmentPath(id), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	ds.segments = append(ds.segments, &diskSegment{id: id, file: file})
+	return nil
+}
+
+//超出预算时淘汰最早的段 当前写入段不会被淘汰 调用方需持有锁
+func (ds *DiskStore) evict() error {
+	for ds.maxBytes > 0 && ds.size > ds.maxBytes && len(ds.segments) > 1 {
+		seg := ds.segments[0]
+		ds.segments = ds.segments[1:]
+		for key, entry := range ds.index {
+			if entry.segment == seg {
+				delete(ds.index, key)
+			}
+		}
+		ds.size -= seg.size
+		seg.file.Close()
+		if err := os.Remove(ds.segmentPath(seg.id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//追加一条记录 调用方需持有锁
+func (ds *DiskStore) append(flag byte, key string, value []byte, expireAt int64) (diskEntry, error) {
+	if ds.closed {
+		return diskEntry{}, ErrDiskStoreClosed
+	}
+	if len(key) > diskMaxKeyLen {
+		return diskEntry{}, fmt.Errorf("cachestore: disk key too long: %d bytes", len(key))
+	}
+	length := int64(diskHeaderSize + len(key) + len(value))
+	if ds.maxBytes > 0 && length > ds.maxBytes {
+		return diskEntry{}, fmt.Errorf("cachestore: disk record of %d bytes exceeds budget", length)
+	}
+	active := ds.segments[len(ds.segments)-1]
+	if active.size > 0 && active.size+length > ds.segmentSize {
+		if err := ds.rotate(); err != nil {
+			return diskEntry{}, err
+		}
+		active = ds.segments[len(ds.segments)-1]
+	}
+	record := make([]byte, length)
+	record[4] = flag
+	binary.BigEndian.PutUint64(record[5:13], uint64(expireAt))
+	binary.BigEndian.PutUint32(record[13:17], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[17:21], uint32(len(value)))
+	copy(record[diskHeaderSize:], key)
+	copy(record[diskHeaderSize+len(key):], value)
+	binary.BigEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(record[4:]))
+	if _, err := active.file.WriteAt(record, active.size); err != nil {
+		return diskEntry{}, err
+	}
+	entry := diskEntry{segment: active, offset: active.size, length: length, expireAt: expireAt}
+	active.size += length
+	ds.size += length
+	return entry, ds.evict()
+}
+
+//Put 写入一个值 ttl小于等于0时永不过期
+func (ds *DiskStore) Put(key string, value []byte, ttl time.Duration) error {
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl).UnixNano()
+	}
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	entry, err := ds.append(diskFlagPut, key, value, expireAt)
+	//记录已写入时即使淘汰旧段出错也更新索引
+	//新记录位于当前写入段，不会被本次写入触发的淘汰移除
+	if entry.length > 0 {
+		ds.index[key] = entry
+	}
+	return err
+}
+
+//Get 读取一个值
+func (ds *DiskStore) Get(key string) ([]byte, bool, error) {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	if ds.closed {
+		return nil, false, ErrDiskStoreClosed
+	}
+	entry, ok := ds.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if entry.expireAt > 0 && time.Now().UnixNano() >= entry.expireAt {
+		delete(ds.index, key)
+		return nil, false, nil
+	}
+	record := make([]byte, entry.length)
+	if _, err := entry.segment.file.ReadAt(record, entry.offset); err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	if crc32.ChecksumIEEE(record[4:]) != binary.BigEndian.Uint32(record[0:4]) {
+		delete(ds.index, key)
+		return nil, false, fmt.Errorf("cachestore: corrupt disk record for key %q", key)
+	}
+	keyLen := int64(binary.BigEndian.Uint32(record[13:17]))
+	return record[diskHeaderSize+keyLen:], true, nil
+}
+
+//Delete 删除一个值，返回值是否存在
+//删除以追加墓碑记录的方式持久化，重新打开后不会恢复已删除的值
+func (ds *DiskStore) Delete(key string) (bool, error) {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	if _, ok := ds.index[key]; !ok {
+		return false, nil
+	}
+	delete(ds.index, key)
+	_, err := ds.append(diskFlagDelete, key, nil, 0)
+	return true, err
+}
+
+//Len 获取索引中的键数量，包括尚未被发现过期的键
+func (ds *DiskStore) Len() int {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return len(ds.index)
+}
+
+//Size 获取段文件的总字节数
+func (ds *DiskStore) Size() int64 {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	return ds.size
+}
+
+//MaxBytes 获取字节预算
+func (ds *DiskStore) MaxBytes() int64 {
+	return ds.maxBytes
+}
+
+//Close 关闭所有段文件
+func (ds *DiskStore) Close() error {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+	if ds.closed {
+		return nil
+	}
+	ds.closed = true
+	var firstErr error
+	for _, seg := range ds.segments {
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,128 @@
+package cachestore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	ds, err := NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.Put("a", []byte("alpha"), 0)
+	ds.Put("b", []byte("beta"), 0)
+	ds.Put("a", []byte("alpha2"), 0)
+	ds.Put("gone", []byte("x"), time.Nanosecond)
+	ds.Delete("b")
+	time.Sleep(time.Millisecond)
+	if v, ok, err := ds.Get("a"); err != nil || !ok || string(v) != "alpha2" {
+		t.Errorf("a = %q %v %v", v, ok, err)
+	}
+	if _, ok, _ := ds.Get("b"); ok {
+		t.Error("b should be deleted")
+	}
+	if _, ok, _ := ds.Get("gone"); ok {
+		t.Error("expired value should not be returned")
+	}
+	ds.Close()
+	if _, _, err := ds.Get("a"); err != ErrDiskStoreClosed {
+		t.Error("closed store should return ErrDiskStoreClosed")
+	}
+
+	//重新打开后从段文件恢复索引，墓碑记录不会恢复已删除的值
+	ds, err = NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	if v, ok, _ := ds.Get("a"); !ok || string(v) != "alpha2" {
+		t.Errorf("reopened a = %q", v)
+	}
+	if _, ok, _ := ds.Get("b"); ok {
+		t.Error("deleted value should stay deleted after reopen")
+	}
+}
+
+func TestDiskStoreBudget(t *testing.T) {
+	ds, err := NewDiskStore(t.TempDir(), 4096, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	value := bytes.Repeat([]byte("x"), 200)
+	for i := 0; i < 100; i++ {
+		if err := ds.Put(fmt.Sprintf("key%03d", i), value, 0); err != nil {
+			t.Fatal(err)
+		}
+		if ds.Size() > ds.MaxBytes() {
+			t.Fatalf("size %d exceeds budget", ds.Size())
+		}
+	}
+	if _, ok, _ := ds.Get("key000"); ok {
+		t.Error("oldest keys should be evicted")
+	}
+	if _, ok, _ := ds.Get("key099"); !ok {
+		t.Error("newest key should be kept")
+	}
+	if err := ds.Put("huge", make([]byte, 5000), 0); err == nil {
+		t.Error("record larger than the budget should be rejected")
+	}
+}
+
+func TestDiskStoreSmallBudget(t *testing.T) {
+	//默认段大小和显式指定的段大小都大于预算
+	for _, segmentSize := range []int64{0, 1 << 20} {
+		ds, err := NewDiskStore(t.TempDir(), 64<<10, segmentSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := bytes.Repeat([]byte("x"), 1024)
+		for i := 0; i < 500; i++ {
+			if err := ds.Put(fmt.Sprintf("key%03d", i), value, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if ds.Size() > ds.MaxBytes() {
+			t.Errorf("segment %d: size %d exceeds budget %d", segmentSize, ds.Size(), ds.MaxBytes())
+		}
+		if _, ok, _ := ds.Get("key499"); !ok {
+			t.Errorf("segment %d: newest key should be kept", segmentSize)
+		}
+		ds.Close()
+	}
+}
+
+func TestDiskStoreTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	ds, _ := NewDiskStore(dir, 0)
+	ds.Put("a", []byte("alpha"), 0)
+	ds.Put("b", []byte("beta"), 0)
+	ds.Close()
+
+	//模拟写入中途崩溃，最后一条记录不完整
+	names, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	info, _ := os.Stat(names[0])
+	os.Truncate(names[0], info.Size()-2)
+
+	ds, err := NewDiskStore(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	if _, ok, _ := ds.Get("b"); ok {
+		t.Error("truncated record should be dropped")
+	}
+	if v, ok, _ := ds.Get("a"); !ok || string(v) != "alpha" {
+		t.Error("records before the damage should survive")
+	}
+	ds.Put("c", []byte("gamma"), 0)
+	if v, ok, _ := ds.Get("c"); !ok || string(v) != "gamma" {
+		t.Error("store should be writable after repairing the tail")
+	}
+}
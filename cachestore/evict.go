@@ -0,0 +1,69 @@
+package cachestore
+
+//EvictReason 元素离开缓存仓的原因
+type EvictReason int
+
+const (
+	//EvictCapacity 容量不足被淘汰策略淘汰
+	EvictCapacity EvictReason = iota
+	//EvictExpired 过期被回收
+	EvictExpired
+	//EvictReplaced 被新的Save覆盖
+	EvictReplaced
+	//EvictDeleted 被主动删除
+	EvictDeleted
+)
+
+//String 返回原因的名称
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictReplaced:
+		return "replaced"
+	case EvictDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+//一次待触发的淘汰回调
+type evictEvent[K comparable, V any] struct {
+	key    K
+	value  V
+	reason EvictReason
+}
+
+//OnEvict 注册淘汰回调
+//元素因容量、过期、覆盖或删除离开缓存仓时调用，可注册多个
+//回调在释放锁之后执行，可以在回调内继续操作缓存仓
+func (cs *CacheStore[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.onEvict = append(cs.onEvict, fn)
+}
+
+//记录一次淘汰，待释放锁后触发回调 调用方需持有锁
+func (cs *CacheStore[K, V]) evicted(key K, value V, reason EvictReason) {
+	if cs.evictHook != nil {
+		cs.evictHook(key, value, reason)
+	}
+	if len(cs.onEvict) > 0 {
+		cs.pending = append(cs.pending, evictEvent[K, V]{key: key, value: value, reason: reason})
+	}
+}
+
+//释放锁并触发锁内产生的淘汰回调
+func (cs *CacheStore[K, V]) unlock() {
+	events := cs.pending
+	handlers := cs.onEvict
+	cs.pending = nil
+	cs.lock.Unlock()
+	for _, e := range events {
+		for _, fn := range handlers {
+			fn(e.key, e.value, e.reason)
+		}
+	}
+}
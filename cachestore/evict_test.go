@@ -0,0 +1,58 @@
+package cachestore
+
+import (
+	"testing"
+	"time"
+)
+
+type evictRecord struct {
+	key    string
+	value  int
+	reason EvictReason
+}
+
+func TestOnEvict(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(2, 0, WithClock[string, int](clock))
+	//关闭后台回收，由测试手动执行doGC
+	cs.Close()
+	var records []evictRecord
+	cs.OnEvict(func(key string, value int, reason EvictReason) {
+		records = append(records, evictRecord{key, value, reason})
+	})
+	cs.Save("a", 1)
+	cs.Save("a", 2)
+	cs.Save("b", 3)
+	cs.Save("c", 4)
+	cs.Delete("b")
+	cs.SaveWithTTL("d", 5, time.Second)
+	clock.Advance(2 * time.Second)
+	cs.doGC()
+	want := []evictRecord{
+		{"a", 1, EvictReplaced},
+		{"a", 2, EvictCapacity},
+		{"b", 3, EvictDeleted},
+		{"d", 5, EvictExpired},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %v, want %v", records, want)
+	}
+	for i := range want {
+		if records[i] != want[i] {
+			t.Errorf("records[%d] = %v, want %v", i, records[i], want[i])
+		}
+	}
+}
+
+func TestOnEvictReentrant(t *testing.T) {
+	cs := NewCacheStore[string, int](1, 0)
+	cs.OnEvict(func(key string, value int, reason EvictReason) {
+		//回调在锁外执行，可以再次访问缓存仓
+		cs.Len()
+	})
+	cs.Save("a", 1)
+	cs.Save("b", 2)
+	if cs.Delete("a") {
+		t.Error("a should already be evicted")
+	}
+}
@@ -0,0 +1,110 @@
+package cachestore
+
+import (
+	"container/heap"
+	"time"
+)
+
+//expireHeap 以回收时间为序的最小堆，实现heap.Interface
+//堆顶为最早需要回收的元素，回收时只需处理已到期的部分
+type expireHeap[K comparable, V any] []*cacheItem[K, V]
+
+func (h expireHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h expireHeap[K, V]) Less(i, j int) bool {
+	return h[i].deadline().Before(h[j].deadline())
+}
+
+func (h expireHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expireHeap[K, V]) Push(x interface{}) {
+	item := x.(*cacheItem[K, V])
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expireHeap[K, V]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[:n-1]
+	return item
+}
+
+//根据存活时间重置过期时间并调整在堆中的位置
+//调用方需持有锁
+func (cs *CacheStore[K, V]) touchItem(item *cacheItem[K, V], now time.Time) {
+	if item.ttl <= 0 {
+		item.gcTime = time.Time{}
+		cs.unscheduleItem(item)
+		return
+	}
+	item.gcTime = now.Add(item.ttl)
+	if item.index >= 0 {
+		heap.Fix(&cs.expires, item.index)
+	} else {
+		heap.Push(&cs.expires, item)
+	}
+}
+
+//将元素从过期堆中移除 调用方需持有锁
+func (cs *CacheStore[K, V]) unscheduleItem(item *cacheItem[K, V]) {
+	if item.index >= 0 {
+		heap.Remove(&cs.expires, item.index)
+	}
+}
+
+//回收已到期的元素 调用方需持有锁
+func (cs *CacheStore[K, V]) expireItems(now time.Time) {
+	for len(cs.expires) > 0 && !now.Before(cs.expires[0].deadline()) {
+		cs.removeItem(cs.expires[0].key, EvictExpired)
+	}
+}
+
+//负缓存队列中的一项
+//负缓存的存活时间固定，写入顺序即过期顺序，队列即可代替堆
+type negativeEntry[K comparable] struct {
+	key  K
+	item *negativeItem
+}
+
+//写入一个负缓存 调用方需持有锁
+func (cs *CacheStore[K, V]) saveNegative(key K, err error, now time.Time) {
+	item := &negativeItem{err: err, gcTime: now.Add(cs.negTTL)}
+	cs.negatives[key] = item
+	cs.negQueue = append(cs.negQueue, negativeEntry[K]{key: key, item: item})
+}
+
+//回收已到期的负缓存 调用方需持有锁
+func (cs *CacheStore[K, V]) expireNegatives(now time.Time) {
+	n := 0
+	for n < len(cs.negQueue) && !now.Before(cs.negQueue[n].item.gcTime) {
+		entry := cs.negQueue[n]
+		//键可能已被重新写入或删除，只回收仍指向该项的负缓存
+		if cs.negatives[entry.key] == entry.item {
+			delete(cs.negatives, entry.key)
+		}
+		cs.negQueue[n] = negativeEntry[K]{}
+		n++
+	}
+	if n > 0 {
+		cs.negQueue = cs.negQueue[n:]
+	}
+}
+
+//直接指定元素的过期时间 调用方需持有锁
+func (cs *CacheStore[K, V]) setExpire(item *cacheItem[K, V], gcTime time.Time) {
+	if item.index < 0 {
+		return
+	}
+	item.gcTime = gcTime
+	heap.Fix(&cs.expires, item.index)
+}
@@ -0,0 +1,105 @@
+package cachestore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDoGCOnlyRemovesDueItems(t *testing.T) {
+	cs := NewCacheStore[int, int](0, 0)
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			cs.SaveWithTTL(i, i, time.Nanosecond)
+		} else {
+			cs.SaveWithTTL(i, i, time.Hour)
+		}
+	}
+	cs.Save(1000, 1000)
+	time.Sleep(time.Millisecond)
+	cs.doGC()
+	if cs.Len() != 51 {
+		t.Errorf("len = %d, want 51", cs.Len())
+	}
+	if len(cs.expires) != 50 {
+		t.Errorf("expire heap size = %d, want 50", len(cs.expires))
+	}
+	cs.SaveWithTTL(1, 1, 0)
+	if len(cs.expires) != 49 {
+		t.Error("saving without ttl should remove the item from the expire heap")
+	}
+}
+
+//sweepStore 旧版按切片遍历的回收实现，仅作为基准测试的对照
+type sweepStore struct {
+	data map[int]*cacheItem[int, int]
+	gcs  []*cacheItem[int, int]
+	lock sync.Mutex
+}
+
+func (ss *sweepStore) save(key int, ttl time.Duration) {
+	ss.lock.Lock()
+	item := &cacheItem[int, int]{key: key, value: key, ttl: ttl, gcTime: time.Now().Add(ttl)}
+	ss.data[key] = item
+	ss.gcs = append(ss.gcs, item)
+	ss.lock.Unlock()
+}
+
+func (ss *sweepStore) doGC() {
+	grayKey := make(map[int]bool, 10)
+	for k, item := range ss.gcs {
+		if item != nil && time.Now().After(item.gcTime) {
+			ss.lock.Lock()
+			delete(ss.data, item.key)
+			ss.lock.Unlock()
+			grayKey[k] = true
+		}
+	}
+	ss.lock.Lock()
+	newGcs := make([]*cacheItem[int, int], 0, cap(ss.gcs))
+	for k, item := range ss.gcs {
+		if _, ok := grayKey[k]; !ok {
+			newGcs = append(newGcs, item)
+		}
+	}
+	ss.gcs = newGcs
+	ss.lock.Unlock()
+}
+
+//每次回收前写入总量1%的已到期元素，对比堆与全量遍历的回收耗时
+func BenchmarkDoGC(b *testing.B) {
+	for _, n := range []int{10000, 100000, 1000000} {
+		due := n / 100
+		b.Run(fmt.Sprintf("heap-%d", n), func(b *testing.B) {
+			cs := NewCacheStore[int, int](0, 0)
+			for i := 0; i < n; i++ {
+				cs.SaveWithTTL(i, i, time.Hour)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for j := 0; j < due; j++ {
+					cs.SaveWithTTL(n+j, j, time.Nanosecond)
+				}
+				b.StartTimer()
+				cs.doGC()
+			}
+		})
+		b.Run(fmt.Sprintf("sweep-%d", n), func(b *testing.B) {
+			ss := &sweepStore{data: make(map[int]*cacheItem[int, int], n)}
+			for i := 0; i < n; i++ {
+				ss.save(i, time.Hour)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for j := 0; j < due; j++ {
+					ss.save(n+j, time.Nanosecond)
+				}
+				b.StartTimer()
+				ss.doGC()
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package cachestore
+
+import (
+	"fmt"
+	"math"
+)
+
+//默认键哈希函数
+//常见的字符串与整数键直接计算，其他类型按%#v格式化后计算
+func defaultHash[K comparable](key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return mix64(uint64(k))
+	case int8:
+		return mix64(uint64(k))
+	case int16:
+		return mix64(uint64(k))
+	case int32:
+		return mix64(uint64(k))
+	case int64:
+		return mix64(uint64(k))
+	case uint:
+		return mix64(uint64(k))
+	case uint8:
+		return mix64(uint64(k))
+	case uint16:
+		return mix64(uint64(k))
+	case uint32:
+		return mix64(uint64(k))
+	case uint64:
+		return mix64(k)
+	case uintptr:
+		return mix64(uint64(k))
+	//-0与+0相等，需要得到相同的哈希值
+	case float32:
+		if k == 0 {
+			k = 0
+		}
+		return mix64(uint64(math.Float32bits(k)))
+	case float64:
+		if k == 0 {
+			k = 0
+		}
+		return mix64(math.Float64bits(k))
+	case bool:
+		if k {
+			return mix64(1)
+		}
+		return mix64(0)
+	default:
+		return hashString(fmt.Sprintf("%#v", key))
+	}
+}
+
+//FNV-1a 64位字符串哈希
+func hashString(s string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime64
+	}
+	return h
+}
+
+//splitmix64 的最终混合步骤，打散相邻整数
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,391 @@
+package httpcache
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasiss-liu/goutils/cachestore"
+)
+
+//默认可缓存的状态码
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+//Response 缓存的完整响应
+//Vary不为空且Variants为true时，该项只记录Vary列表，实际响应按请求头保存在各自的键下
+type Response struct {
+	Status   int
+	Header   http.Header
+	Body     []byte
+	StoredAt time.Time
+	Vary     []string
+	Variants bool
+}
+
+//Cache HTTP响应缓存中间件
+//只缓存GET与HEAD请求，遵循Cache-Control(max-age、s-maxage、no-store、no-cache、private)、Vary，
+//并以ETag/Last-Modified响应条件请求；同一个键并发的请求只有一个会执行下游处理
+//其他方法的成功请求会使同一URL的缓存失效
+type Cache struct {
+	store *cachestore.CacheStore[string, *Response]
+
+	//DefaultTTL 响应未指定max-age时的缓存时间，0表示不缓存这类响应
+	DefaultTTL time.Duration
+	//KeyFunc 计算请求的缓存键，默认为方法+URL
+	KeyFunc func(r *http.Request) string
+
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+//正在生成中的响应 同一个键的后续请求等待其完成
+type flight struct {
+	done      chan struct{}
+	resp      *Response
+	cacheable bool
+	variant   string
+}
+
+//New 创建以store保存响应的缓存中间件
+func New(store *cachestore.CacheStore[string, *Response]) *Cache {
+	return &Cache{
+		store:   store,
+		KeyFunc: defaultKey,
+		flights: make(map[string]*flight),
+	}
+}
+
+//Handler 返回在next之前进行缓存的http.Handler
+func (c *Cache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			c.serveUnsafe(w, r, next)
+			return
+		}
+		reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+		if _, ok := reqCC["no-store"]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := c.KeyFunc(r)
+		if _, ok := reqCC["no-cache"]; !ok {
+			if resp, ok := c.lookup(key, r); ok {
+				serve(w, r, resp, true)
+				return
+			}
+		}
+
+		c.lock.Lock()
+		if f, ok := c.flights[key]; ok {
+			c.lock.Unlock()
+			<-f.done
+			//只有可缓存且与本请求的Vary取值一致的响应才能共享
+			if f.cacheable && f.variant == variantKey(key, f.resp.Vary, r) {
+				serve(w, r, f.resp, true)
+				return
+			}
+			c.generate(w, r, next, key, nil)
+			return
+		}
+		f := &flight{done: make(chan struct{})}
+		c.flights[key] = f
+		c.lock.Unlock()
+
+		c.generate(w, r, next, key, f)
+	})
+}
+
+//执行下游处理，缓存并输出响应
+//f不为nil时在结束后唤醒等待同一个键的请求
+func (c *Cache) generate(w http.ResponseWriter, r *http.Request, next http.Handler, key string, f *flight) {
+	rec := &recorder{header: make(http.Header)}
+	defer func() {
+		if f != nil {
+			c.lock.Lock()
+			delete(c.flights, key)
+			c.lock.Unlock()
+			close(f.done)
+		}
+	}()
+	next.ServeHTTP(rec, r)
+	resp := rec.response()
+	resp.StoredAt = time.Now()
+	cacheable := false
+	if ttl, ok := c.freshness(r, resp); ok {
+		cacheable = c.save(key, r, resp, ttl)
+	}
+	if f != nil {
+		f.resp = resp
+		f.cacheable = cacheable
+		f.variant = variantKey(key, resp.Vary, r)
+	}
+	serve(w, r, resp, false)
+}
+
+//非GET/HEAD请求直接交给下游处理，成功后删除该URL下的所有缓存
+func (c *Cache) serveUnsafe(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(sw, r)
+	if sw.status < 400 {
+		c.store.InvalidateTag(urlTag(r))
+	}
+}
+
+//查找请求对应的缓存响应
+func (c *Cache) lookup(key string, r *http.Request) (*Response, bool) {
+	resp, ok := c.store.GetOK(key)
+	if !ok {
+		return nil, false
+	}
+	if resp.Variants {
+		return c.store.GetOK(variantKey(key, resp.Vary, r))
+	}
+	return resp, true
+}
+
+//保存响应，返回是否已缓存
+func (c *Cache) save(key string, r *http.Request, resp *Response, ttl time.Duration) bool {
+	tag := urlTag(r)
+	if len(resp.Vary) == 0 {
+		return c.store.SaveTaggedWithTTL(key, resp, ttl, tag)
+	}
+	//基础键只记录Vary列表，存活时间不短于各个变体
+	marker := &Response{Vary: resp.Vary, Variants: true}
+	if old, ok := c.store.Peek(key); !ok || !old.Variants || !sameVary(old.Vary, resp.Vary) {
+		c.store.SaveTaggedWithTTL(key, marker, ttl, tag)
+	} else if remain, _ := c.store.TTL(key); remain > 0 && remain < ttl {
+		c.store.Expire(key, ttl)
+	}
+	return c.store.SaveTaggedWithTTL(variantKey(key, resp.Vary, r), resp, ttl, tag)
+}
+
+//计算响应的缓存时间，不可缓存时返回false
+func (c *Cache) freshness(r *http.Request, resp *Response) (time.Duration, bool) {
+	if !cacheableStatus[resp.Status] {
+		return 0, false
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	for _, v := range resp.Vary {
+		if v == "*" {
+			return 0, false
+		}
+	}
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["private"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["no-cache"]; ok {
+		return 0, false
+	}
+	_, public := cc["public"]
+	sMaxAge, hasShared := cc["s-maxage"]
+	if r.Header.Get("Authorization") != "" && !public && !hasShared {
+		return 0, false
+	}
+	//共享缓存优先使用s-maxage
+	for _, v := range []string{sMaxAge, cc["max-age"]} {
+		if v == "" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if c.DefaultTTL > 0 {
+		return c.DefaultTTL, true
+	}
+	return 0, false
+}
+
+//输出响应，并处理条件请求
+func serve(w http.ResponseWriter, r *http.Request, resp *Response, cached bool) {
+	h := w.Header()
+	for k, v := range resp.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	if cached {
+		h.Set("Age", strconv.FormatInt(int64(time.Since(resp.StoredAt)/time.Second), 10))
+	}
+	if resp.Status == http.StatusOK && notModified(r, resp) {
+		for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			h.Del(k)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(resp.Status)
+	if r.Method != http.MethodHead {
+		w.Write(resp.Body)
+	}
+}
+
+//判断条件请求是否可以返回304
+//If-None-Match存在时忽略If-Modified-Since
+func notModified(r *http.Request, resp *Response) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || weakEqual(candidate, etag) {
+				return true
+			}
+		}
+		return false
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	lm := resp.Header.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+//弱比较ETag 忽略W/前缀
+func weakEqual(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+//解析Cache-Control 指令名转为小写，去掉值的引号
+func parseCacheControl(s string) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value := part, ""
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name, value = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+		}
+		cc[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return cc
+}
+
+//解析响应的Vary头 规范化并排序
+func parseVary(h http.Header) []string {
+	var vary []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				vary = append(vary, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	sort.Strings(vary)
+	return vary
+}
+
+func sameVary(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//按Vary中的请求头取值计算变体的键
+func variantKey(key string, vary []string, r *http.Request) string {
+	if len(vary) == 0 {
+		return key
+	}
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("\x00")
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+func defaultKey(r *http.Request) string {
+	return r.Method + " " + r.URL.String()
+}
+
+//同一URL的所有缓存共用的标签，用于非安全方法使其失效
+func urlTag(r *http.Request) string {
+	return "url:" + r.URL.String()
+}
+
+//recorder 记录下游处理输出的响应
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) response() *Response {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return &Response{
+		Status: rec.status,
+		Header: rec.header.Clone(),
+		Body:   rec.body.Bytes(),
+		Vary:   parseVary(rec.header),
+	}
+}
+
+//statusWriter 记录写出的状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
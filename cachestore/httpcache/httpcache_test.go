@@ -0,0 +1,210 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kasiss-liu/goutils/cachestore"
+)
+
+func newTestCache(h http.HandlerFunc) (*Cache, http.Handler) {
+	c := New(cachestore.NewCacheStore[string, *Response](0, 0))
+	return c, c.Handler(h)
+}
+
+func do(h http.Handler, method, target string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCacheControl(t *testing.T) {
+	var calls int32
+	_, h := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/public":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Header().Set("X-Call", string(rune('0'+n)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.URL.Path))
+	})
+
+	for _, path := range []string{"/public", "/private", "/nostore", "/none"} {
+		atomic.StoreInt32(&calls, 0)
+		first := do(h, "GET", path, nil)
+		second := do(h, "GET", path, nil)
+		if first.Body.String() != path || second.Body.String() != path {
+			t.Fatalf("%s: unexpected bodies %q %q", path, first.Body, second.Body)
+		}
+		want := int32(2)
+		if path == "/public" {
+			want = 1
+			if second.Header().Get("X-Call") != "1" || second.Header().Get("Age") == "" {
+				t.Fatalf("%s: cached response headers = %v", path, second.Header())
+			}
+		}
+		if got := atomic.LoadInt32(&calls); got != want {
+			t.Fatalf("%s: handler called %d times, want %d", path, got, want)
+		}
+	}
+
+	//请求的no-cache跳过查找
+	atomic.StoreInt32(&calls, 0)
+	do(h, "GET", "/public", map[string]string{"Cache-Control": "no-cache"})
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatal("request no-cache should bypass the cache")
+	}
+	//带Authorization且未声明public的响应不缓存
+	atomic.StoreInt32(&calls, 0)
+	do(h, "GET", "/public?auth", map[string]string{"Authorization": "Bearer x"})
+	do(h, "GET", "/public?auth", map[string]string{"Authorization": "Bearer x"})
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatal("authorized response should not be shared")
+	}
+}
+
+func TestVary(t *testing.T) {
+	var calls int32
+	_, h := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "accept-language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	})
+	for i := 0; i < 2; i++ {
+		for _, lang := range []string{"en", "zh"} {
+			w := do(h, "GET", "/", map[string]string{"Accept-Language": lang})
+			if w.Body.String() != lang {
+				t.Fatalf("got %q for %s", w.Body, lang)
+			}
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("handler called %d times, want 2", calls)
+	}
+
+	_, star := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "*")
+	})
+	calls = 0
+	do(star, "GET", "/", nil)
+	do(star, "GET", "/", nil)
+	if calls != 2 {
+		t.Fatal("Vary: * should not be cached")
+	}
+}
+
+func TestConditional(t *testing.T) {
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, h := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	})
+	cases := []struct {
+		header map[string]string
+		status int
+	}{
+		{nil, http.StatusOK},
+		{map[string]string{"If-None-Match": `"v1"`}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"v0", W/"v1"`}, http.StatusNotModified},
+		{map[string]string{"If-None-Match": `"v2"`}, http.StatusOK},
+		{map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified},
+		{map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		//If-None-Match优先
+		{map[string]string{"If-None-Match": `"v2"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusOK},
+	}
+	for i, c := range cases {
+		w := do(h, "GET", "/", c.header)
+		if w.Code != c.status {
+			t.Fatalf("case %d: status %d, want %d", i, w.Code, c.status)
+		}
+		if c.status == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != `"v1"`) {
+			t.Fatalf("case %d: bad 304 response %q %v", i, w.Body, w.Header())
+		}
+	}
+}
+
+func TestInvalidateOnUnsafe(t *testing.T) {
+	var version int32
+	_, h := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			atomic.AddInt32(&version, 1)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte{byte('0' + atomic.LoadInt32(&version))})
+	})
+	if do(h, "GET", "/item", nil).Body.String() != "0" {
+		t.Fatal("unexpected first body")
+	}
+	do(h, "POST", "/item", nil)
+	if got := do(h, "GET", "/item", nil).Body.String(); got != "1" {
+		t.Fatalf("got %q after POST, want 1", got)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	_, h := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("ok"))
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := do(h, "GET", "/slow", nil); w.Body.String() != "ok" {
+				t.Errorf("got %q", w.Body)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+
+	//不可缓存的响应 等待者各自执行处理
+	calls = 0
+	_, nostore := newTestCache(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Cache-Control", "no-store")
+	})
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			do(nostore, "GET", "/", nil)
+		}()
+	}
+	wg.Wait()
+	if calls != 5 {
+		t.Fatalf("handler called %d times, want 5", calls)
+	}
+}
@@ -0,0 +1,156 @@
+package cachestore
+
+import "time"
+
+//GetOK 获取一个元素值，并返回元素是否存在
+//用于区分未命中与保存的零值
+func (cs *CacheStore[K, V]) GetOK(key K) (V, bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	return cs.getItem(key, cs.clock.Now())
+}
+
+//Peek 读取一个元素值但不影响淘汰顺序、滑动过期与命中统计
+func (cs *CacheStore[K, V]) Peek(key K) (V, bool) {
+	var res V
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if item, ok := cs.data[key]; ok && !item.expired(cs.clock.Now()) {
+		return item.value, true
+	}
+	return res, false
+}
+
+//Keys 获取所有未过期元素的键，顺序不固定
+func (cs *CacheStore[K, V]) Keys() []K {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	now := cs.clock.Now()
+	keys := make([]K, 0, len(cs.data))
+	for key, item := range cs.data {
+		if !item.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+//Range 遍历所有未过期的元素，fn返回false时停止遍历
+//遍历的是调用时的快照，fn在锁外执行，可以在fn内操作缓存仓
+func (cs *CacheStore[K, V]) Range(fn func(key K, value V) bool) {
+	type pair struct {
+		key   K
+		value V
+	}
+	cs.lock.Lock()
+	now := cs.clock.Now()
+	pairs := make([]pair, 0, len(cs.data))
+	for key, item := range cs.data {
+		if !item.expired(now) {
+			pairs = append(pairs, pair{key, item.value})
+		}
+	}
+	cs.lock.Unlock()
+	for _, p := range pairs {
+		if !fn(p.key, p.value) {
+			return
+		}
+	}
+}
+
+//Clear 删除所有元素
+func (cs *CacheStore[K, V]) Clear() {
+	cs.lock.Lock()
+	defer cs.unlock()
+	now := cs.clock.Now()
+	for key, item := range cs.data {
+		if item.expired(now) {
+			cs.removeItem(key, EvictExpired)
+		} else {
+			cs.removeItem(key, EvictDeleted)
+		}
+	}
+	for key := range cs.loading {
+		cs.cancelLoad(key)
+	}
+	for key := range cs.negatives {
+		delete(cs.negatives, key)
+	}
+	cs.negQueue = nil
+}
+
+//Update 原子地读取并修改一个元素
+//fn接收当前值及其是否存在，返回新值及是否保留：
+//返回true时保存新值，已存在的元素保持原有的过期时间；返回false时删除该元素
+//fn在锁内执行，不能在fn内操作缓存仓
+//返回值为最终保存的值及是否保存成功
+func (cs *CacheStore[K, V]) Update(key K, fn func(old V, ok bool) (V, bool)) (V, bool) {
+	var res V
+	cs.lock.Lock()
+	defer cs.unlock()
+	now := cs.clock.Now()
+	item, exists := cs.data[key]
+	if exists && item.expired(now) {
+		cs.removeItem(key, EvictExpired)
+		item, exists = nil, false
+	}
+	var old V
+	if exists {
+		old = item.value
+	}
+	value, keep := fn(old, exists)
+	if !keep {
+		cs.removeItem(key, EvictDeleted)
+		return res, false
+	}
+	ttl := cs.lifetime
+	var gcTime time.Time
+	if exists {
+		ttl, gcTime = item.ttl, item.gcTime
+	}
+	cs.cancelLoad(key)
+	if !cs.saveItem(key, value, ttl, now) {
+		return res, false
+	}
+	if exists && ttl > 0 {
+		cs.setExpire(cs.data[key], gcTime)
+	}
+	return value, true
+}
+
+//TTL 获取元素剩余的存活时间
+//元素不存在时返回false，元素永不过期时返回0
+func (cs *CacheStore[K, V]) TTL(key K) (time.Duration, bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	now := cs.clock.Now()
+	item, ok := cs.data[key]
+	if !ok || item.expired(now) {
+		return 0, false
+	}
+	if item.ttl <= 0 {
+		return 0, true
+	}
+	return item.gcTime.Sub(now), true
+}
+
+//Expire 重新设置元素的存活时间，从当前时间开始计算
+//ttl小于等于0时元素改为永不过期，元素不存在时返回false
+func (cs *CacheStore[K, V]) Expire(key K, ttl time.Duration) bool {
+	if ttl > 0 {
+		cs.startGC()
+	}
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	now := cs.clock.Now()
+	item, ok := cs.data[key]
+	if !ok || item.expired(now) {
+		return false
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	item.ttl = ttl
+	cs.touchItem(item, now)
+	return true
+}
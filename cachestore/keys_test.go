@@ -0,0 +1,137 @@
+package cachestore
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetOKAndPeek(t *testing.T) {
+	cs := NewCacheStore(2, 0, WithLRU[string, int]())
+	cs.Save("zero", 0)
+	if v, ok := cs.GetOK("zero"); !ok || v != 0 {
+		t.Error("stored zero value should be found")
+	}
+	if _, ok := cs.GetOK("missing"); ok {
+		t.Error("missing key should not be found")
+	}
+	cs.Save("b", 2)
+	//Peek不更新最近使用顺序，写入c时仍淘汰zero
+	if v, ok := cs.Peek("zero"); !ok || v != 0 {
+		t.Error("peek should find zero")
+	}
+	cs.Save("c", 3)
+	if _, ok := cs.Peek("zero"); ok {
+		t.Error("peek should not touch recency")
+	}
+}
+
+func TestKeysRangeClear(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 0, WithClock[string, int](clock))
+	cs.Close()
+	cs.Save("a", 1)
+	cs.Save("b", 2)
+	cs.SaveWithTTL("c", 3, time.Second)
+	clock.Advance(2 * time.Second)
+
+	keys := cs.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("keys = %v", keys)
+	}
+	sum := 0
+	cs.Range(func(key string, value int) bool {
+		sum += value
+		cs.Delete(key)
+		return true
+	})
+	if sum != 3 || cs.Len() != 1 {
+		t.Errorf("sum = %d len = %d", sum, cs.Len())
+	}
+	n := 0
+	cs.Save("d", 4)
+	cs.Save("e", 5)
+	cs.Range(func(string, int) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Error("range should stop when fn returns false")
+	}
+	var deleted []string
+	cs.OnEvict(func(key string, value int, reason EvictReason) {
+		if reason == EvictDeleted {
+			deleted = append(deleted, key)
+		}
+	})
+	cs.Clear()
+	if cs.Len() != 0 || len(deleted) != 2 {
+		t.Errorf("len = %d deleted = %v", cs.Len(), deleted)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 0, WithClock[string, int](clock))
+	cs.Close()
+	incr := func(old int, ok bool) (int, bool) {
+		return old + 1, true
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cs.Update("counter", incr)
+		}()
+	}
+	wg.Wait()
+	if v := cs.Get("counter"); v != 100 {
+		t.Errorf("counter = %d, want 100", v)
+	}
+
+	cs.SaveWithTTL("ttl", 1, 10*time.Second)
+	clock.Advance(6 * time.Second)
+	cs.Update("ttl", incr)
+	clock.Advance(6 * time.Second)
+	if _, ok := cs.GetOK("ttl"); ok {
+		t.Error("update should keep the original expiration")
+	}
+
+	if _, ok := cs.Update("counter", func(int, bool) (int, bool) { return 0, false }); ok {
+		t.Error("update returning false should report not stored")
+	}
+	if _, ok := cs.GetOK("counter"); ok {
+		t.Error("update returning false should delete the entry")
+	}
+}
+
+func TestTTLAndExpire(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 0, WithClock[string, int](clock))
+	cs.Close()
+	cs.Save("a", 1)
+	if ttl, ok := cs.TTL("a"); !ok || ttl != 0 {
+		t.Errorf("ttl = %v %v, want no expiration", ttl, ok)
+	}
+	if !cs.Expire("a", 10*time.Second) {
+		t.Fatal("expire should find a")
+	}
+	clock.Advance(4 * time.Second)
+	if ttl, _ := cs.TTL("a"); ttl != 6*time.Second {
+		t.Errorf("ttl = %v, want 6s", ttl)
+	}
+	cs.Expire("a", 0)
+	clock.Advance(time.Minute)
+	if _, ok := cs.GetOK("a"); !ok {
+		t.Error("expire with 0 should persist the entry")
+	}
+	if cs.Expire("missing", time.Second) {
+		t.Error("expire should report missing keys")
+	}
+	if _, ok := cs.TTL("missing"); ok {
+		t.Error("ttl should report missing keys")
+	}
+}
@@ -0,0 +1,113 @@
+package cachestore
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//正在进行中的加载调用
+//同一个键的并发调用方等待同一个结果
+//加载期间键被写入、删除或缓存仓被清空时canceled被置为true，结果只返回给等待方，不再保存
+type loadCall[V any] struct {
+	wg       sync.WaitGroup
+	val      V
+	err      error
+	canceled bool //由缓存仓的锁保护
+}
+
+//加载失败的负缓存
+type negativeItem struct {
+	err    error
+	gcTime time.Time
+}
+
+//GetOrLoad 获取一个元素值，未命中时调用loader加载并保存
+//同一个键同时只会执行一次loader，其余调用方等待该次结果
+//开启负缓存时，loader返回的错误会在负缓存存活时间内直接返回
+//开启提前刷新时，临近过期或已过期但仍在容忍期内的元素会直接返回旧值并在后台刷新
+func (cs *CacheStore[K, V]) GetOrLoad(key K, loader func(K) (V, error)) (V, error) {
+	cs.lock.Lock()
+	now := cs.clock.Now()
+	if v, ok := cs.getItem(key, now); ok {
+		if cs.refreshDue(key, now) {
+			cs.refresh(key, loader)
+		}
+		cs.lock.Unlock()
+		return v, nil
+	}
+	if v, ok := cs.getStale(key, now); ok {
+		cs.refresh(key, loader)
+		cs.lock.Unlock()
+		return v, nil
+	}
+	if neg, ok := cs.negatives[key]; ok {
+		if now.Before(neg.gcTime) {
+			cs.lock.Unlock()
+			var res V
+			return res, neg.err
+		}
+		delete(cs.negatives, key)
+	}
+	if call, ok := cs.loading[key]; ok {
+		cs.lock.Unlock()
+		call.wg.Wait()
+		return call.val, call.err
+	}
+	call := &loadCall[V]{}
+	call.wg.Add(1)
+	cs.loading[key] = call
+	cs.lock.Unlock()
+
+	cs.doLoad(key, call, loader)
+	return call.val, call.err
+}
+
+//执行加载并保存结果
+//loader发生panic时转为错误返回，避免等待方永久阻塞
+func (cs *CacheStore[K, V]) doLoad(key K, call *loadCall[V], loader func(K) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = fmt.Errorf("cachestore: loader panic: %v", r)
+		}
+		atomic.AddUint64(&cs.stats.loads, 1)
+		if call.err != nil {
+			atomic.AddUint64(&cs.stats.loadErrors, 1)
+		}
+		cs.lock.Lock()
+		cs.finishLoad(key, call)
+		now := cs.clock.Now()
+		//加载期间键已被删除时丢弃结果
+		if !call.canceled {
+			if call.err == nil {
+				if cs.saveItem(key, call.val, cs.lifetime, now) {
+					cs.markLoaded(cs.data[key])
+				}
+			} else if cs.negTTL > 0 && cs.data[key] == nil {
+				//后台刷新失败时保留旧值，不写入负缓存
+				cs.saveNegative(key, call.err, now)
+			}
+		}
+		cs.unlock()
+		call.wg.Done()
+	}()
+	call.val, call.err = loader(key)
+}
+
+//取消一个键正在进行的加载 调用方需持有锁
+//在删除、清空以及加载以外的写入时调用，避免较旧的加载结果覆盖之后的修改
+//之后的GetOrLoad会发起新的加载，而不是等待被取消的结果
+func (cs *CacheStore[K, V]) cancelLoad(key K) {
+	if call, ok := cs.loading[key]; ok {
+		call.canceled = true
+		delete(cs.loading, key)
+	}
+}
+
+//加载结束，移除仍登记为该键当前加载的调用 调用方需持有锁
+func (cs *CacheStore[K, V]) finishLoad(key K, call *loadCall[V]) {
+	if cs.loading[key] == call {
+		delete(cs.loading, key)
+	}
+}
@@ -0,0 +1,191 @@
+package cachestore
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	cs := NewCacheStore[string, int](10, time.Minute)
+	var calls int32
+	loader := func(key string) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return len(key), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cs.GetOrLoad("hello", loader)
+			if err != nil || v != 5 {
+				t.Errorf("GetOrLoad = %d, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("loader called %d times, want 1", calls)
+	}
+	if cs.Get("hello") != 5 {
+		t.Error("loaded value should be saved")
+	}
+}
+
+func TestGetOrLoadNegativeTTL(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, time.Minute, WithNegativeTTL[string, int](30*time.Second), WithClock[string, int](clock))
+	defer cs.Close()
+	errNotFound := errors.New("not found")
+	calls := 0
+	loader := func(key string) (int, error) {
+		calls++
+		return 0, errNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := cs.GetOrLoad("missing", loader); err != errNotFound {
+			t.Fatalf("err = %v, want %v", err, errNotFound)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader called %d times, want 1", calls)
+	}
+	clock.Advance(40 * time.Second)
+	cs.GetOrLoad("missing", loader)
+	if calls != 2 {
+		t.Errorf("loader called %d times after negative ttl, want 2", calls)
+	}
+	cs.Save("missing", 1)
+	if v, err := cs.GetOrLoad("missing", loader); err != nil || v != 1 {
+		t.Error("Save should clear the negative entry")
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	cs := NewCacheStore[string, int](10, 0)
+	_, err := cs.GetOrLoad("boom", func(string) (int, error) {
+		panic("boom")
+	})
+	if err == nil {
+		t.Error("loader panic should be returned as error")
+	}
+	if v, err := cs.GetOrLoad("boom", func(string) (int, error) { return 1, nil }); err != nil || v != 1 {
+		t.Error("key should be loadable after a panicking loader")
+	}
+}
+
+func TestGetOrLoadCanceled(t *testing.T) {
+	cs := NewCacheStore[string, int](10, 0)
+	for name, remove := range map[string]func(){
+		"delete": func() { cs.Delete("k") },
+		"clear":  cs.Clear,
+	} {
+		started, release := make(chan struct{}), make(chan struct{})
+		loader := func(string) (int, error) {
+			close(started)
+			<-release
+			return 1, nil
+		}
+		done := make(chan int)
+		go func() {
+			v, _ := cs.GetOrLoad("k", loader)
+			done <- v
+		}()
+		<-started
+		remove()
+		//删除后的加载不等待被取消的调用
+		if v, _ := cs.GetOrLoad("k", func(string) (int, error) { return 2, nil }); v != 2 {
+			t.Errorf("%s: load after removal = %d, want 2", name, v)
+		}
+		cs.Delete("k")
+		close(release)
+		if v := <-done; v != 1 {
+			t.Errorf("%s: waiter got %d, want 1", name, v)
+		}
+		if _, ok := cs.GetOK("k"); ok {
+			t.Errorf("%s: canceled load should not be saved", name)
+		}
+	}
+
+	//批量加载同样不会写回被删除的键
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan map[string]int)
+	go func() {
+		res, _ := cs.GetOrLoadMany([]string{"a", "b"}, func(keys []string) (map[string]int, error) {
+			close(started)
+			<-release
+			return map[string]int{"a": 1, "b": 2}, nil
+		})
+		done <- res
+	}()
+	<-started
+	cs.Delete("a")
+	close(release)
+	if res := <-done; res["a"] != 1 || res["b"] != 2 {
+		t.Errorf("batch result = %v", res)
+	}
+	if _, ok := cs.GetOK("a"); ok {
+		t.Error("canceled batch key should not be saved")
+	}
+	if v, ok := cs.GetOK("b"); !ok || v != 2 {
+		t.Error("other batch keys should still be saved")
+	}
+}
+
+func TestGetOrLoadOverwritten(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, time.Minute, WithClock[string, int](clock), WithRefreshAhead[string, int](0.5, 0))
+	defer cs.Close()
+	for name, write := range map[string]func(){
+		"save":   func() { cs.Save("k", 5) },
+		"tagged": func() { cs.SaveTagged("k", 5, "t") },
+		"many":   func() { cs.SaveMany(map[string]int{"k": 5}) },
+		"update": func() { cs.Update("k", func(int, bool) (int, bool) { return 5, true }) },
+	} {
+		cs.Delete("k")
+		started, release := make(chan struct{}), make(chan struct{})
+		done := make(chan int)
+		go func() {
+			v, _ := cs.GetOrLoad("k", func(string) (int, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+			done <- v
+		}()
+		<-started
+		write()
+		close(release)
+		if v := <-done; v != 1 {
+			t.Errorf("%s: waiter got %d, want 1", name, v)
+		}
+		if v := cs.Get("k"); v != 5 {
+			t.Errorf("%s: stale load overwrote the write, got %d", name, v)
+		}
+	}
+
+	//后台刷新同样不会覆盖之后的写入
+	cs.Delete("k")
+	cs.GetOrLoad("k", func(string) (int, error) { return 5, nil })
+	started, release := make(chan struct{}), make(chan struct{})
+	clock.Advance(40 * time.Second)
+	if v, _ := cs.GetOrLoad("k", func(string) (int, error) {
+		close(started)
+		<-release
+		return 1, nil
+	}); v != 5 {
+		t.Fatalf("refresh should return the current value, got %d", v)
+	}
+	<-started
+	cs.Save("k", 7)
+	close(release)
+	//等待后台刷新结束
+	time.Sleep(20 * time.Millisecond)
+	if v := cs.Get("k"); v != 7 {
+		t.Fatalf("refresh overwrote a newer value, got %d", v)
+	}
+}
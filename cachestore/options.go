@@ -0,0 +1,127 @@
+package cachestore
+
+import "time"
+
+//Option 缓存仓的可选配置
+//同一个Option可能被应用到多个缓存仓（如分片缓存的每个分片），
+//因此不应在Option之间共享有状态的对象；WithSnapshot例外，分片缓存仓只为整体开启一份快照
+type Option[K comparable, V any] func(*CacheStore[K, V])
+
+//WithPolicy 指定淘汰策略的构造函数，默认为NewFIFOPolicy
+func WithPolicy[K comparable, V any](newPolicy func() EvictionPolicy[K]) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.policy = newPolicy()
+	}
+}
+
+//WithLRU 使用最近最少使用淘汰策略
+func WithLRU[K comparable, V any]() Option[K, V] {
+	return WithPolicy[K, V](NewLRUPolicy[K])
+}
+
+//WithLFU 使用最不经常使用淘汰策略
+func WithLFU[K comparable, V any]() Option[K, V] {
+	return WithPolicy[K, V](NewLFUPolicy[K])
+}
+
+//WithFIFO 使用先进先出淘汰策略
+func WithFIFO[K comparable, V any]() Option[K, V] {
+	return WithPolicy[K, V](NewFIFOPolicy[K])
+}
+
+//WithSlidingExpiration 开启滑动过期
+//每次成功的Get都会按元素自身的存活时间重新计算过期时间，
+//元素只有在闲置超过存活时间后才会过期
+func WithSlidingExpiration[K comparable, V any]() Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.sliding = true
+	}
+}
+
+//WithNegativeTTL 开启负缓存
+//GetOrLoad中loader返回的错误会被缓存ttl时间，期间同一个键不再重复加载
+func WithNegativeTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.negTTL = ttl
+	}
+}
+
+//WithClock 指定缓存仓使用的时钟，默认为系统时钟
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.clock = clock
+	}
+}
+
+//WithHasher 指定键的哈希函数，用于分片缓存选择分片
+//默认对字符串和整数键直接计算，其他类型按%#v格式化后计算
+func WithHasher[K comparable, V any](hasher func(K) uint64) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.hasher = hasher
+	}
+}
+
+//WithCodec 指定快照使用的编解码器，默认为GobCodec
+func WithCodec[K comparable, V any](codec Codec) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.codec = codec
+	}
+}
+
+//WithSnapshot 开启定期快照
+//创建缓存仓时从path恢复已有快照，之后每隔interval写入一次，关闭时再写入一次
+//interval小于等于0时不定期写入，只在关闭时写入
+//onError可选，用于接收读写快照时发生的错误
+//用于NewShardedCacheStore时快照作用于整个分片缓存仓，而不是每个分片各写一份
+func WithSnapshot[K comparable, V any](path string, interval time.Duration, onError ...func(error)) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		conf := &snapshotConfig{path: path, interval: interval}
+		if len(onError) > 0 {
+			conf.onError = onError[0]
+		}
+		cs.snapshot = conf
+	}
+}
+
+//WithCost 按成本限制缓存仓容量
+//cost计算单个元素的成本（如字节数），写入时持续淘汰直到总成本不超过maxCost
+//maxCost小于等于0时只统计成本不做限制；cap仍然限制元素个数，可传0只按成本限制
+func WithCost[K comparable, V any](cost func(K, V) int64, maxCost int64) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.costFn = cost
+		cs.maxCost = maxCost
+	}
+}
+
+//WithRefreshAhead 为GetOrLoad加载的元素开启提前刷新与过期旧值返回
+//元素存活时间经过ahead比例(0~1)后，GetOrLoad在返回当前值的同时在后台刷新；ahead为0时不提前刷新
+//元素过期后maxStale时间内，GetOrLoad返回旧值并在后台刷新，超过maxStale后才阻塞加载
+func WithRefreshAhead[K comparable, V any](ahead float64, maxStale time.Duration) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.refreshConf = &refreshConfig{ahead: ahead, maxStale: maxStale}
+	}
+}
+
+//WithAdmission 指定准入策略的构造函数，参数为缓存仓的容量
+//缓存仓已满时，新键只有被准入策略接受才会淘汰现有元素写入，否则Save返回false
+func WithAdmission[K comparable, V any](newAdmission func(capacity int) AdmissionPolicy) Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		cs.admission = newAdmission(cs.cap)
+	}
+}
+
+//WithTinyLFU 使用TinyLFU准入策略，防止一次性访问的键冲刷缓存
+//准入策略只过滤写入，淘汰仍由淘汰策略决定；需要完整的W-TinyLFU时使用WithWTinyLFU
+func WithTinyLFU[K comparable, V any]() Option[K, V] {
+	return WithAdmission[K, V](NewTinyLFU)
+}
+
+//WithWTinyLFU 使用W-TinyLFU淘汰策略
+//新键总是写入窗口区，由窗口区与主区的频率比较决定淘汰哪一个，Save不会因准入被拒绝
+//与WithTinyLFU的准入策略相互独立，一般不需要同时使用
+func WithWTinyLFU[K comparable, V any]() Option[K, V] {
+	return func(cs *CacheStore[K, V]) {
+		//哈希函数可能由之后的WithHasher设置，调用时再读取
+		cs.policy = NewWTinyLFUPolicy(cs.cap, func(key K) uint64 { return cs.hasher(key) })
+	}
+}
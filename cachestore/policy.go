@@ -0,0 +1,170 @@
+package cachestore
+
+import (
+	"container/heap"
+	"container/list"
+)
+
+//EvictionPolicy 淘汰策略接口
+//决定缓存仓容量不足时淘汰哪一个键
+//所有方法都在缓存仓的锁内调用，实现无需自行加锁
+type EvictionPolicy[K comparable] interface {
+	//Add 新键写入缓存仓
+	Add(key K)
+	//Access 已存在的键被读取或覆盖写入
+	Access(key K)
+	//Remove 键已从缓存仓中移除（过期、淘汰等）
+	Remove(key K)
+	//Victim 返回下一个应被淘汰的键，没有可淘汰的键时返回false
+	Victim() (K, bool)
+}
+
+//NewFIFOPolicy 先进先出策略，按写入顺序淘汰，访问不影响顺序
+func NewFIFOPolicy[K comparable]() EvictionPolicy[K] {
+	return &fifoPolicy[K]{
+		order: list.New(),
+		elems: make(map[K]*list.Element),
+	}
+}
+
+//NewLRUPolicy 最近最少使用策略，淘汰最久未被访问的键
+func NewLRUPolicy[K comparable]() EvictionPolicy[K] {
+	return &lruPolicy[K]{fifoPolicy[K]{
+		order: list.New(),
+		elems: make(map[K]*list.Element),
+	}}
+}
+
+//NewLFUPolicy 最不经常使用策略，淘汰访问次数最少的键
+//访问次数相同时淘汰最久未被访问的键
+func NewLFUPolicy[K comparable]() EvictionPolicy[K] {
+	return &lfuPolicy[K]{
+		entries: make(map[K]*lfuEntry[K]),
+	}
+}
+
+//先进先出 链表头部为最早写入的键
+type fifoPolicy[K comparable] struct {
+	order *list.List
+	elems map[K]*list.Element
+}
+
+func (p *fifoPolicy[K]) Add(key K) {
+	if _, ok := p.elems[key]; ok {
+		return
+	}
+	p.elems[key] = p.order.PushBack(key)
+}
+
+func (p *fifoPolicy[K]) Access(key K) {}
+
+func (p *fifoPolicy[K]) Remove(key K) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *fifoPolicy[K]) Victim() (K, bool) {
+	var key K
+	e := p.order.Front()
+	if e == nil {
+		return key, false
+	}
+	return e.Value.(K), true
+}
+
+//最近最少使用 在先进先出基础上，访问时移动到链表尾部
+type lruPolicy[K comparable] struct {
+	fifoPolicy[K]
+}
+
+func (p *lruPolicy[K]) Access(key K) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToBack(e)
+	}
+}
+
+//最不经常使用 以(访问次数, 最后访问序号)为序的最小堆
+type lfuPolicy[K comparable] struct {
+	entries map[K]*lfuEntry[K]
+	heap    lfuHeap[K]
+	seq     uint64
+}
+
+type lfuEntry[K comparable] struct {
+	key   K
+	freq  uint64
+	seq   uint64
+	index int
+}
+
+func (p *lfuPolicy[K]) Add(key K) {
+	if _, ok := p.entries[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.seq++
+	e := &lfuEntry[K]{key: key, freq: 1, seq: p.seq}
+	p.entries[key] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *lfuPolicy[K]) Access(key K) {
+	if e, ok := p.entries[key]; ok {
+		p.seq++
+		e.freq++
+		e.seq = p.seq
+		heap.Fix(&p.heap, e.index)
+	}
+}
+
+func (p *lfuPolicy[K]) Remove(key K) {
+	if e, ok := p.entries[key]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, key)
+	}
+}
+
+func (p *lfuPolicy[K]) Victim() (K, bool) {
+	var key K
+	if len(p.heap) == 0 {
+		return key, false
+	}
+	return p.heap[0].key, true
+}
+
+//lfuHeap 实现heap.Interface
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) Len() int {
+	return len(h)
+}
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x interface{}) {
+	e := x.(*lfuEntry[K])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
@@ -0,0 +1,99 @@
+package cachestore
+
+import (
+	"testing"
+)
+
+func TestFIFOPolicy(t *testing.T) {
+	cs := NewCacheStore[string, int](3, 0)
+	cs.Save("a", 1)
+	cs.Save("b", 2)
+	cs.Save("c", 3)
+	cs.Get("a")
+	cs.Save("d", 4)
+	if cs.Get("a") != 0 {
+		t.Error("fifo should evict the oldest key a")
+	}
+	if cs.Len() != 3 {
+		t.Errorf("len = %d, want 3", cs.Len())
+	}
+}
+
+func TestLRUPolicy(t *testing.T) {
+	cs := NewCacheStore(3, 0, WithLRU[string, int]())
+	cs.Save("a", 1)
+	cs.Save("b", 2)
+	cs.Save("c", 3)
+	cs.Get("a")
+	cs.Save("d", 4)
+	if cs.Get("a") != 1 {
+		t.Error("lru should keep recently used key a")
+	}
+	if cs.Get("b") != 0 {
+		t.Error("lru should evict least recently used key b")
+	}
+}
+
+func TestLFUPolicy(t *testing.T) {
+	cs := NewCacheStore(3, 0, WithLFU[string, int]())
+	cs.Save("a", 1)
+	cs.Save("b", 2)
+	cs.Save("c", 3)
+	for i := 0; i < 3; i++ {
+		cs.Get("a")
+		cs.Get("c")
+	}
+	cs.Get("b")
+	cs.Save("d", 4)
+	if cs.Get("b") != 0 {
+		t.Error("lfu should evict least frequently used key b")
+	}
+	if cs.Get("a") != 1 || cs.Get("c") != 3 {
+		t.Error("lfu should keep frequently used keys")
+	}
+}
+
+//自定义策略：永远淘汰最后写入的键
+type lifoPolicy[K comparable] struct {
+	keys []K
+}
+
+func (p *lifoPolicy[K]) Add(key K)    { p.keys = append(p.keys, key) }
+func (p *lifoPolicy[K]) Access(key K) {}
+func (p *lifoPolicy[K]) Remove(key K) {
+	for i, k := range p.keys {
+		if k == key {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			return
+		}
+	}
+}
+func (p *lifoPolicy[K]) Victim() (K, bool) {
+	var key K
+	if len(p.keys) == 0 {
+		return key, false
+	}
+	return p.keys[len(p.keys)-1], true
+}
+
+func TestCustomPolicy(t *testing.T) {
+	cs := NewCacheStore(2, 0, WithPolicy[string, int](func() EvictionPolicy[string] {
+		return &lifoPolicy[string]{}
+	}))
+	cs.Save("a", 1)
+	cs.Save("b", 2)
+	cs.Save("c", 3)
+	if cs.Get("b") != 0 || cs.Get("a") != 1 || cs.Get("c") != 3 {
+		t.Error("custom policy should evict the newest key b")
+	}
+}
+
+func TestSaveExistingKey(t *testing.T) {
+	cs := NewCacheStore[string, int](2, 0)
+	cs.Save("a", 1)
+	cs.Save("a", 2)
+	cs.Save("b", 3)
+	if cs.Len() != 2 || cs.Get("a") != 2 {
+		t.Error("overwriting a key should not take extra capacity")
+	}
+}
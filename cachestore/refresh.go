@@ -0,0 +1,59 @@
+package cachestore
+
+import (
+	"container/heap"
+	"time"
+)
+
+//提前刷新配置
+type refreshConfig struct {
+	ahead    float64       //存活时间经过该比例后触发后台刷新，0表示不提前刷新
+	maxStale time.Duration //过期后仍可返回旧值的最长时间
+}
+
+//标记元素由loader加载，并按配置设置旧值容忍期 调用方需持有锁
+func (cs *CacheStore[K, V]) markLoaded(item *cacheItem[K, V]) {
+	item.loaded = true
+	if cs.refreshConf == nil || item.ttl <= 0 {
+		return
+	}
+	item.grace = cs.refreshConf.maxStale
+	if item.index >= 0 {
+		heap.Fix(&cs.expires, item.index)
+	}
+}
+
+//未过期的元素是否应提前刷新 调用方需持有锁
+func (cs *CacheStore[K, V]) refreshDue(key K, now time.Time) bool {
+	if cs.refreshConf == nil || cs.refreshConf.ahead <= 0 {
+		return false
+	}
+	item := cs.data[key]
+	if !item.loaded || item.ttl <= 0 {
+		return false
+	}
+	threshold := time.Duration(float64(item.ttl) * cs.refreshConf.ahead)
+	return now.Sub(item.created) >= threshold
+}
+
+//读取已过期但仍在容忍期内的旧值 调用方需持有锁
+func (cs *CacheStore[K, V]) getStale(key K, now time.Time) (V, bool) {
+	var res V
+	item, ok := cs.data[key]
+	if !ok || !item.loaded || item.grace <= 0 || !now.Before(item.deadline()) {
+		return res, false
+	}
+	cs.policy.Access(key)
+	return item.value, true
+}
+
+//在后台刷新一个元素，已有加载在进行时不重复刷新 调用方需持有锁
+func (cs *CacheStore[K, V]) refresh(key K, loader func(K) (V, error)) {
+	if _, ok := cs.loading[key]; ok {
+		return
+	}
+	call := &loadCall[V]{}
+	call.wg.Add(1)
+	cs.loading[key] = call
+	go cs.doLoad(key, call, loader)
+}
@@ -0,0 +1,96 @@
+package cachestore
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//返回调用次数的loader，每次调用后向done发送信号
+func countingLoader(calls *int32, done chan struct{}) func(string) (int, error) {
+	return func(string) (int, error) {
+		n := atomic.AddInt32(calls, 1)
+		if done != nil {
+			defer func() { done <- struct{}{} }()
+		}
+		return int(n), nil
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 10*time.Second, WithClock[string, int](clock), WithRefreshAhead[string, int](0.8, 0))
+	cs.Close()
+	var calls int32
+	done := make(chan struct{}, 1)
+	loader := countingLoader(&calls, done)
+
+	if v, _ := cs.GetOrLoad("k", loader); v != 1 {
+		t.Fatalf("v = %d, want 1", v)
+	}
+	<-done
+	clock.Advance(5 * time.Second)
+	if v, _ := cs.GetOrLoad("k", loader); v != 1 || atomic.LoadInt32(&calls) != 1 {
+		t.Error("no refresh before the ahead fraction")
+	}
+	clock.Advance(4 * time.Second)
+	if v, _ := cs.GetOrLoad("k", loader); v != 1 {
+		t.Error("refresh ahead should return the current value")
+	}
+	<-done
+	waitFor(t, func() bool { return cs.Get("k") == 2 })
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 10*time.Second, WithClock[string, int](clock), WithRefreshAhead[string, int](0, 5*time.Second))
+	cs.Close()
+	var calls int32
+	release := make(chan struct{})
+	loader := func(string) (int, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			<-release
+		}
+		return int(n), nil
+	}
+	cs.GetOrLoad("k", loader)
+
+	//过期后容忍期内返回旧值，且只触发一次后台刷新
+	clock.Advance(12 * time.Second)
+	for i := 0; i < 3; i++ {
+		if v, err := cs.GetOrLoad("k", loader); v != 1 || err != nil {
+			t.Fatalf("v = %d err = %v, want stale 1", v, err)
+		}
+	}
+	if _, ok := cs.GetOK("k"); ok {
+		t.Error("plain Get should not return stale values")
+	}
+	//回收只会在容忍期结束后发生
+	cs.doGC()
+	if cs.Len() != 1 {
+		t.Error("stale entry should survive gc within max staleness")
+	}
+	close(release)
+	waitFor(t, func() bool { return cs.Get("k") == 2 })
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestStaleBound(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(10, 10*time.Second, WithClock[string, int](clock), WithRefreshAhead[string, int](0, 5*time.Second))
+	cs.Close()
+	var calls int32
+	loader := countingLoader(&calls, nil)
+	cs.GetOrLoad("k", loader)
+	clock.Advance(16 * time.Second)
+	cs.doGC()
+	if cs.Len() != 0 {
+		t.Error("entry should be collected after max staleness")
+	}
+	if v, _ := cs.GetOrLoad("k", loader); v != 2 {
+		t.Errorf("v = %d, want a blocking reload", v)
+	}
+}
@@ -0,0 +1,542 @@
+package respserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasiss-liu/goutils/cachestore"
+)
+
+//ErrServerClosed 服务已关闭
+var ErrServerClosed = errors.New("respserver: server closed")
+
+//单个请求的参数个数与长度上限，防止异常客户端耗尽内存
+const (
+	maxArgs      = 1 << 20
+	maxInlineLen = 64 << 10
+	bulkChunk    = 64 << 10 //读取参数时每次分配的大小
+	argsPrealloc = 64       //按声明的参数个数预分配的上限，超出后随读取扩容
+)
+
+//DefaultMaxBulkLen 单个参数的默认长度上限
+const DefaultMaxBulkLen = 64 << 20
+
+//Server 以Redis协议(RESP)对外提供CacheStore的服务
+//支持 GET SET(EX/PX) DEL EXISTS TTL EXPIRE INCR MGET MSET KEYS FLUSHALL PING QUIT
+//未指定过期时间的写入(不带EX/PX的SET、MSET、INCR新建的键)使用缓存仓的默认存活时间，
+//与Redis的永不过期不同；需要永不过期时以存活时间0创建缓存仓。INCR修改已有的键时保留其过期时间
+type Server struct {
+	store *cachestore.CacheStore[string, []byte]
+
+	//MaxBulkLen 单个参数的长度上限，小于等于0时使用DefaultMaxBulkLen，需在Serve之前设置
+	//参数按实际收到的数据分块读取，声明的长度不会被提前分配
+	MaxBulkLen int
+
+	lock      sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+//NewServer 创建一个以store为存储的服务
+func NewServer(store *cachestore.CacheStore[string, []byte]) *Server {
+	return &Server{
+		store:     store,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+//ListenAndServe 监听network(tcp或unix)上的addr并提供服务
+//unix socket文件已存在时会先删除
+func (s *Server) ListenAndServe(network, addr string) error {
+	if network == "unix" {
+		os.Remove(addr)
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+//Serve 在l上接受连接并提供服务，直到l出错或服务关闭
+func (s *Server) Serve(l net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.listeners, l)
+		s.lock.Unlock()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+//Close 关闭所有监听与连接，并等待连接处理结束
+//不会关闭底层的CacheStore
+func (s *Server) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+//处理一个连接上的请求
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		s.wg.Done()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	maxBulk := s.MaxBulkLen
+	if maxBulk <= 0 {
+		maxBulk = DefaultMaxBulkLen
+	}
+	for {
+		args, err := readCommand(r, maxBulk)
+		if err != nil {
+			var perr protocolError
+			if errors.As(err, &perr) {
+				writeError(w, "ERR Protocol error: "+string(perr))
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(w, args)
+		//管道中还有待处理的请求时合并写出
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+//执行一条命令，返回是否关闭连接
+func (s *Server) execute(w *bufio.Writer, args [][]byte) bool {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		writeError(w, "ERR unknown command '"+string(args[0])+"'")
+		return false
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		writeError(w, "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command")
+		return false
+	}
+	cmd.fn(s, w, args)
+	return name == "QUIT"
+}
+
+//命令定义 arity为正数时参数个数(含命令名)必须相等，为负数时至少为其绝对值
+type command struct {
+	arity int
+	fn    func(s *Server, w *bufio.Writer, args [][]byte)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"PING":     {-1, cmdPing},
+		"QUIT":     {1, cmdQuit},
+		"COMMAND":  {-1, cmdCommand},
+		"GET":      {2, cmdGet},
+		"SET":      {-3, cmdSet},
+		"DEL":      {-2, cmdDel},
+		"EXISTS":   {-2, cmdExists},
+		"TTL":      {2, cmdTTL},
+		"EXPIRE":   {3, cmdExpire},
+		"INCR":     {2, cmdIncr},
+		"MGET":     {-2, cmdMGet},
+		"MSET":     {-3, cmdMSet},
+		"KEYS":     {2, cmdKeys},
+		"FLUSHALL": {-1, cmdFlushAll},
+	}
+}
+
+func cmdPing(s *Server, w *bufio.Writer, args [][]byte) {
+	if len(args) > 1 {
+		writeBulk(w, args[1])
+		return
+	}
+	writeSimple(w, "PONG")
+}
+
+func cmdQuit(s *Server, w *bufio.Writer, args [][]byte) {
+	writeSimple(w, "OK")
+}
+
+//客户端连接时可能发送COMMAND查询命令表，返回空数组即可
+func cmdCommand(s *Server, w *bufio.Writer, args [][]byte) {
+	writeArrayHeader(w, 0)
+}
+
+func cmdGet(s *Server, w *bufio.Writer, args [][]byte) {
+	if v, ok := s.store.GetOK(string(args[1])); ok {
+		writeBulk(w, v)
+		return
+	}
+	writeNil(w)
+}
+
+//SET key value [EX seconds|PX milliseconds]
+func cmdSet(s *Server, w *bufio.Writer, args [][]byte) {
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		opt := strings.ToUpper(string(args[i]))
+		if (opt != "EX" && opt != "PX") || i+1 >= len(args) || ttl != 0 {
+			writeError(w, "ERR syntax error")
+			return
+		}
+		unit := time.Millisecond
+		if opt == "EX" {
+			unit = time.Second
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil || n <= 0 || n > maxExpire(unit) {
+			writeError(w, "ERR invalid expire time in 'set' command")
+			return
+		}
+		ttl = time.Duration(n) * unit
+		i++
+	}
+	var ok bool
+	if ttl > 0 {
+		ok = s.store.SaveWithTTL(string(args[1]), args[2], ttl)
+	} else {
+		ok = s.store.Save(string(args[1]), args[2])
+	}
+	if !ok {
+		writeError(w, "ERR value rejected by cache")
+		return
+	}
+	writeSimple(w, "OK")
+}
+
+func cmdDel(s *Server, w *bufio.Writer, args [][]byte) {
+	n := 0
+	for _, key := range args[1:] {
+		if s.store.Delete(string(key)) {
+			n++
+		}
+	}
+	writeInt(w, int64(n))
+}
+
+func cmdExists(s *Server, w *bufio.Writer, args [][]byte) {
+	n := 0
+	for _, key := range args[1:] {
+		if _, ok := s.store.Peek(string(key)); ok {
+			n++
+		}
+	}
+	writeInt(w, int64(n))
+}
+
+//键不存在返回-2，永不过期返回-1，否则返回四舍五入的剩余秒数
+func cmdTTL(s *Server, w *bufio.Writer, args [][]byte) {
+	ttl, ok := s.store.TTL(string(args[1]))
+	switch {
+	case !ok:
+		writeInt(w, -2)
+	case ttl == 0:
+		writeInt(w, -1)
+	default:
+		writeInt(w, int64((ttl+500*time.Millisecond)/time.Second))
+	}
+}
+
+//与Redis一致，秒数小于等于0时直接删除键
+func cmdExpire(s *Server, w *bufio.Writer, args [][]byte) {
+	n, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+	if n > maxExpire(time.Second) {
+		writeError(w, "ERR invalid expire time in 'expire' command")
+		return
+	}
+	key := string(args[1])
+	var ok bool
+	if n <= 0 {
+		ok = s.store.Delete(key)
+	} else {
+		ok = s.store.Expire(key, time.Duration(n)*time.Second)
+	}
+	if ok {
+		writeInt(w, 1)
+	} else {
+		writeInt(w, 0)
+	}
+}
+
+//以unit为单位可表示的最大存活时间，超出时time.Duration溢出
+func maxExpire(unit time.Duration) int64 {
+	return int64(math.MaxInt64 / unit)
+}
+
+//值不是整数时先检查后返回错误，不经过Update重写元素
+//检查与Update之间值被并发改为非整数时，Update原样写回旧值
+func cmdIncr(s *Server, w *bufio.Writer, args [][]byte) {
+	key := string(args[1])
+	if old, ok := s.store.Peek(key); ok {
+		if _, ok := parseCounter(old); !ok {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+	}
+	var result int64
+	var invalid bool
+	s.store.Update(key, func(old []byte, ok bool) ([]byte, bool) {
+		var n int64
+		if ok {
+			if n, ok = parseCounter(old); !ok {
+				invalid = true
+				return old, true
+			}
+		}
+		result = n + 1
+		return strconv.AppendInt(nil, result, 10), true
+	})
+	if invalid {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+	writeInt(w, result)
+}
+
+//解析计数器的值，不是整数或已达到上限时返回false
+func parseCounter(b []byte) (int64, bool) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	return n, err == nil && n != math.MaxInt64
+}
+
+func cmdMGet(s *Server, w *bufio.Writer, args [][]byte) {
+	writeArrayHeader(w, len(args)-1)
+	for _, key := range args[1:] {
+		if v, ok := s.store.GetOK(string(key)); ok {
+			writeBulk(w, v)
+		} else {
+			writeNil(w)
+		}
+	}
+}
+
+func cmdMSet(s *Server, w *bufio.Writer, args [][]byte) {
+	if len(args)%2 != 1 {
+		writeError(w, "ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	for i := 1; i < len(args); i += 2 {
+		s.store.Save(string(args[i]), args[i+1])
+	}
+	writeSimple(w, "OK")
+}
+
+func cmdKeys(s *Server, w *bufio.Writer, args [][]byte) {
+	pattern := string(args[1])
+	var keys []string
+	for _, key := range s.store.Keys() {
+		if matchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	writeArrayHeader(w, len(keys))
+	for _, key := range keys {
+		writeBulk(w, []byte(key))
+	}
+}
+
+func cmdFlushAll(s *Server, w *bufio.Writer, args [][]byte) {
+	s.store.Clear()
+	writeSimple(w, "OK")
+}
+
+//protocolError 请求格式错误
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "respserver: protocol error: " + string(e)
+}
+
+//读取一条命令，支持RESP数组与以空格分隔的内联命令
+//maxBulk为单个参数的长度上限
+func readCommand(r *bufio.Reader, maxBulk int) ([][]byte, error) {
+	line, err := readLine(r, maxInlineLen)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, f := range fields {
+			args[i] = []byte(f)
+		}
+		return args, nil
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, protocolError("invalid multibulk length")
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	size := n
+	if size > argsPrealloc {
+		size = argsPrealloc
+	}
+	args := make([][]byte, 0, size)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r, maxInlineLen)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, protocolError("expected '$'")
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulk {
+			return nil, protocolError("invalid bulk length")
+		}
+		buf, err := readBulk(r, size+2)
+		if err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, protocolError("bulk string not terminated by CRLF")
+		}
+		args = append(args, buf[:size])
+	}
+	return args, nil
+}
+
+//读取n个字节 按bulkChunk分块扩容，只声明长度而不发送数据的客户端无法让服务端分配大块内存
+func readBulk(r *bufio.Reader, n int) ([]byte, error) {
+	size := n
+	if size > bulkChunk {
+		size = bulkChunk
+	}
+	buf := make([]byte, 0, size)
+	for len(buf) < n {
+		chunk := n - len(buf)
+		if chunk > bulkChunk {
+			chunk = bulkChunk
+		}
+		if cap(buf)-len(buf) < chunk {
+			grown := make([]byte, len(buf), 2*cap(buf)+chunk)
+			copy(grown, buf)
+			buf = grown
+		}
+		start := len(buf)
+		buf = buf[:start+chunk]
+		if _, err := io.ReadFull(r, buf[start:]); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+//读取一行 去掉结尾的\r\n
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > limit {
+			return nil, protocolError("line too long")
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, s string) {
+	w.WriteString("-" + s + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func writeNil(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeArrayHeader(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
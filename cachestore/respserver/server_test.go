@@ -0,0 +1,305 @@
+package respserver
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kasiss-liu/goutils/cachestore"
+)
+
+//测试用的最小RESP客户端 以字符串形式返回回复
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, network, addr string) *client {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) do(t *testing.T, args ...string) string {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := c.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+//将回复转为可比较的字符串：简单字符串与整数原样，nil为(nil)，数组为[a b]
+func (c *client) read() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', '-', ':':
+		return line, nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return "(nil)", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := c.r.Read(buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		items := make([]string, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return "", err
+			}
+		}
+		return "[" + strings.Join(items, " ") + "]", nil
+	}
+	return "", fmt.Errorf("unexpected reply %q", line)
+}
+
+func startServer(t *testing.T) (*Server, *cachestore.CacheStore[string, []byte], string) {
+	store := cachestore.NewCacheStore[string, []byte](100, 0)
+	srv := NewServer(store)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		srv.Close()
+		store.Close()
+	})
+	return srv, store, l.Addr().String()
+}
+
+func TestCommands(t *testing.T) {
+	_, _, addr := startServer(t)
+	c := dial(t, "tcp", addr)
+	defer c.conn.Close()
+
+	cases := [][]string{
+		{"+PONG", "PING"},
+		{"+OK", "SET", "name", "cache"},
+		{"cache", "GET", "name"},
+		{"(nil)", "GET", "missing"},
+		{":-1", "TTL", "name"},
+		{":-2", "TTL", "missing"},
+		{"+OK", "SET", "session", "s", "EX", "100"},
+		{":100", "TTL", "session"},
+		{"+OK", "SET", "short", "s", "PX", "2400"},
+		{":2", "TTL", "short"},
+		{"-ERR syntax error", "SET", "k", "v", "NX"},
+		{"-ERR invalid expire time in 'set' command", "SET", "k", "v", "EX", "9223372036854775807"},
+		{"-ERR invalid expire time in 'set' command", "SET", "k", "v", "PX", "9223372036854776"},
+		{"(nil)", "GET", "k"},
+		{":1", "EXPIRE", "name", "50"},
+		{":50", "TTL", "name"},
+		{":0", "EXPIRE", "missing", "50"},
+		{"-ERR invalid expire time in 'expire' command", "EXPIRE", "name", "9223372036854775807"},
+		{":1", "INCR", "counter"},
+		{":2", "INCR", "counter"},
+		{"-ERR value is not an integer or out of range", "INCR", "name"},
+		{"+OK", "MSET", "a", "1", "b", "2"},
+		{"[1 (nil) 2]", "MGET", "a", "x", "b"},
+		{":2", "EXISTS", "a", "b", "x"},
+		{":1", "DEL", "a", "x"},
+		{"[b]", "KEYS", "[b-c]"},
+		{"-ERR unknown command 'NOPE'", "NOPE"},
+		{"-ERR wrong number of arguments for 'get' command", "GET"},
+		{"+OK", "FLUSHALL"},
+		{"[]", "KEYS", "*"},
+	}
+	for _, tc := range cases {
+		if got := c.do(t, tc[1:]...); got != tc[0] {
+			t.Errorf("%v = %q, want %q", tc[1:], got, tc[0])
+		}
+	}
+}
+
+func TestDefaultLifetime(t *testing.T) {
+	store := cachestore.NewCacheStore[string, []byte](100, time.Hour)
+	defer store.Close()
+	srv := NewServer(store)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	//未指定过期时间的写入都使用缓存仓的默认存活时间
+	c := dial(t, "tcp", l.Addr().String())
+	defer c.conn.Close()
+	c.do(t, "SET", "set", "v")
+	c.do(t, "MSET", "mset", "v")
+	c.do(t, "INCR", "incr")
+	for _, key := range []string{"set", "mset", "incr"} {
+		if got := c.do(t, "TTL", key); got != ":3600" {
+			t.Errorf("TTL %s = %q, want :3600", key, got)
+		}
+	}
+	c.do(t, "SET", "ex", "v", "EX", "10")
+	if got := c.do(t, "TTL", "ex"); got != ":10" {
+		t.Errorf("TTL ex = %q, want :10", got)
+	}
+}
+
+func TestIncrInvalid(t *testing.T) {
+	_, store, addr := startServer(t)
+	var replaced int32
+	store.OnEvict(func(key string, value []byte, reason cachestore.EvictReason) {
+		if reason == cachestore.EvictReplaced {
+			atomic.AddInt32(&replaced, 1)
+		}
+	})
+	c := dial(t, "tcp", addr)
+	defer c.conn.Close()
+	c.do(t, "SET", "name", "cache")
+	//非整数值不应被重写
+	if got := c.do(t, "INCR", "name"); got != "-ERR value is not an integer or out of range" {
+		t.Fatalf("INCR = %q", got)
+	}
+	if n := atomic.LoadInt32(&replaced); n != 0 {
+		t.Errorf("invalid INCR replaced the value %d times", n)
+	}
+}
+
+func TestInlineAndPipeline(t *testing.T) {
+	_, store, addr := startServer(t)
+	c := dial(t, "tcp", addr)
+	defer c.conn.Close()
+	c.conn.Write([]byte("SET a 1\r\nSET b 2\r\nMGET a b\r\n"))
+	for _, want := range []string{"+OK", "+OK", "[1 2]"} {
+		if got, err := c.read(); err != nil || got != want {
+			t.Errorf("reply = %q %v, want %q", got, err, want)
+		}
+	}
+	if store.Len() != 2 {
+		t.Errorf("store len = %d", store.Len())
+	}
+}
+
+func TestBulkLimit(t *testing.T) {
+	store := cachestore.NewCacheStore[string, []byte](100, 0)
+	defer store.Close()
+	srv := NewServer(store)
+	srv.MaxBulkLen = 16
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	c := dial(t, "tcp", l.Addr().String())
+	defer c.conn.Close()
+	if got := c.do(t, "SET", "k", strings.Repeat("v", 16)); got != "+OK" {
+		t.Fatalf("value at the limit = %q", got)
+	}
+	if got := c.do(t, "SET", "k", strings.Repeat("v", 17)); got != "-ERR Protocol error: invalid bulk length" {
+		t.Fatalf("value over the limit = %q", got)
+	}
+}
+
+func TestReadBulk(t *testing.T) {
+	data := strings.Repeat("0123456789", bulkChunk/4)
+	buf, err := readBulk(bufio.NewReader(strings.NewReader(data)), len(data))
+	if err != nil || string(buf) != data {
+		t.Fatalf("read %d bytes, err = %v", len(buf), err)
+	}
+	//声明的长度大于实际数据时返回错误
+	if _, err := readBulk(bufio.NewReader(strings.NewReader(data)), 64<<20); err == nil {
+		t.Fatal("short input should fail")
+	}
+}
+
+func TestReadCommandArgs(t *testing.T) {
+	//只声明参数个数而不发送参数时不按声明的个数分配
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := readCommand(bufio.NewReader(strings.NewReader("*1048576\r\n$1\r\na\r\n")), DefaultMaxBulkLen); err == nil {
+		t.Fatal("truncated command should fail")
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a truncated command", n)
+	}
+	args, err := readCommand(bufio.NewReader(strings.NewReader("*100\r\n"+strings.Repeat("$1\r\na\r\n", 100))), DefaultMaxBulkLen)
+	if err != nil || len(args) != 100 {
+		t.Fatalf("read %d args, err = %v", len(args), err)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	store := cachestore.NewCacheStore[string, []byte](10, time.Minute)
+	defer store.Close()
+	srv := NewServer(store)
+	path := filepath.Join(t.TempDir(), "cache.sock")
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe("unix", path) }()
+	var c *client
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("unix", path); err == nil {
+			c = &client{conn: conn, r: bufio.NewReader(conn)}
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c == nil {
+		t.Fatal("unix socket not ready")
+	}
+	if got := c.do(t, "SET", "k", "v"); got != "+OK" {
+		t.Errorf("SET = %q", got)
+	}
+	srv.Close()
+	if err := <-errc; err != ErrServerClosed {
+		t.Errorf("ListenAndServe = %v, want ErrServerClosed", err)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "anything", true},
+		{"user:*", "user:42", true},
+		{"user:*", "post:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"*:*:count", "user:42:count", true},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{"[abc", "[abc", true},
+	}
+	for _, c := range cases {
+		if got := matchPattern(c.pattern, c.s); got != c.want {
+			t.Errorf("matchPattern(%q, %q) = %v", c.pattern, c.s, got)
+		}
+	}
+}
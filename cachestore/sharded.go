@@ -0,0 +1,186 @@
+package cachestore
+
+import (
+	"sync"
+	"time"
+)
+
+//ShardedCacheStore 分片缓存仓
+//按键的哈希值将元素分散到多个独立加锁的缓存仓，降低高并发下的锁竞争
+//容量、存活时间与淘汰策略均作用于单个分片
+type ShardedCacheStore[K comparable, V any] struct {
+	shards []*CacheStore[K, V]
+	hasher func(K) uint64
+
+	snapshot  *snapshotConfig
+	done      chan struct{}
+	closeOnce sync.Once
+	workers   sync.WaitGroup
+}
+
+//获取键所在的分片
+func (ss *ShardedCacheStore[K, V]) shard(key K) *CacheStore[K, V] {
+	return ss.shards[ss.hasher(key)%uint64(len(ss.shards))]
+}
+
+//Shards 获取分片数量
+func (ss *ShardedCacheStore[K, V]) Shards() int {
+	return len(ss.shards)
+}
+
+//Len 获取所有分片的当前存储量之和
+func (ss *ShardedCacheStore[K, V]) Len() int {
+	n := 0
+	for _, s := range ss.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+//Cap 获取所有分片的容量之和
+func (ss *ShardedCacheStore[K, V]) Cap() int {
+	n := 0
+	for _, s := range ss.shards {
+		n += s.Cap()
+	}
+	return n
+}
+
+//Cost 获取所有分片的当前总成本之和
+func (ss *ShardedCacheStore[K, V]) Cost() int64 {
+	var n int64
+	for _, s := range ss.shards {
+		n += s.Cost()
+	}
+	return n
+}
+
+//Save 保存一个元素值
+func (ss *ShardedCacheStore[K, V]) Save(key K, value V) bool {
+	return ss.shard(key).Save(key, value)
+}
+
+//SaveWithTTL 以单独的存活时间保存一个元素值
+func (ss *ShardedCacheStore[K, V]) SaveWithTTL(key K, value V, ttl time.Duration) bool {
+	return ss.shard(key).SaveWithTTL(key, value, ttl)
+}
+
+//Get 获取一个元素值
+func (ss *ShardedCacheStore[K, V]) Get(key K) V {
+	return ss.shard(key).Get(key)
+}
+
+//GetOrLoad 获取一个元素值，未命中时调用loader加载并保存
+func (ss *ShardedCacheStore[K, V]) GetOrLoad(key K, loader func(K) (V, error)) (V, error) {
+	return ss.shard(key).GetOrLoad(key, loader)
+}
+
+//Close 关闭所有分片
+//开启了定期快照时先写入最后一次快照
+func (ss *ShardedCacheStore[K, V]) Close() error {
+	ss.closeOnce.Do(func() {
+		close(ss.done)
+	})
+	ss.workers.Wait()
+	for _, s := range ss.shards {
+		s.Close()
+	}
+	return nil
+}
+
+//NewShardedCacheStore 获取一个新的分片缓存仓
+//shards为分片数量，cap与lifetime作用于每个分片
+//WithSnapshot作用于整个分片缓存仓：所有分片写入同一个快照文件，恢复时按键的哈希值分配到各分片
+func NewShardedCacheStore[K comparable, V any](shards, cap int, lifetime time.Duration, opts ...Option[K, V]) *ShardedCacheStore[K, V] {
+	if shards < 1 {
+		shards = 1
+	}
+	ss := &ShardedCacheStore[K, V]{
+		shards: make([]*CacheStore[K, V], shards),
+		done:   make(chan struct{}),
+	}
+	//快照由分片缓存仓统一处理，不交给各个分片
+	opts = append(opts[:len(opts):len(opts)], func(cs *CacheStore[K, V]) {
+		ss.snapshot = cs.snapshot
+		cs.snapshot = nil
+	})
+	for i := range ss.shards {
+		ss.shards[i] = NewCacheStore(cap, lifetime, opts...)
+	}
+	ss.hasher = ss.shards[0].hasher
+	if ss.snapshot != nil {
+		runSnapshot(ss.snapshot, ss.shards[0].clock, ss.done, &ss.workers, ss.LoadSnapshot, ss.SaveSnapshot)
+	}
+	return ss
+}
+
+//GetOK 获取一个元素值，并返回元素是否存在
+func (ss *ShardedCacheStore[K, V]) GetOK(key K) (V, bool) {
+	return ss.shard(key).GetOK(key)
+}
+
+//Peek 读取一个元素值但不影响淘汰顺序、滑动过期与命中统计
+func (ss *ShardedCacheStore[K, V]) Peek(key K) (V, bool) {
+	return ss.shard(key).Peek(key)
+}
+
+//Update 原子地读取并修改一个元素
+func (ss *ShardedCacheStore[K, V]) Update(key K, fn func(old V, ok bool) (V, bool)) (V, bool) {
+	return ss.shard(key).Update(key, fn)
+}
+
+//Keys 获取所有分片中未过期元素的键
+func (ss *ShardedCacheStore[K, V]) Keys() []K {
+	var keys []K
+	for _, s := range ss.shards {
+		keys = append(keys, s.Keys()...)
+	}
+	return keys
+}
+
+//Range 依次遍历所有分片，fn返回false时停止遍历
+func (ss *ShardedCacheStore[K, V]) Range(fn func(key K, value V) bool) {
+	next := true
+	for _, s := range ss.shards {
+		s.Range(func(key K, value V) bool {
+			next = fn(key, value)
+			return next
+		})
+		if !next {
+			return
+		}
+	}
+}
+
+//Clear 清空所有分片
+func (ss *ShardedCacheStore[K, V]) Clear() {
+	for _, s := range ss.shards {
+		s.Clear()
+	}
+}
+
+//SaveTagged 保存一个元素值并为其打上标签
+func (ss *ShardedCacheStore[K, V]) SaveTagged(key K, value V, tags ...string) bool {
+	return ss.shard(key).SaveTagged(key, value, tags...)
+}
+
+//InvalidateTag 删除所有分片中带有该标签的元素，返回删除的个数
+func (ss *ShardedCacheStore[K, V]) InvalidateTag(tag string) int {
+	n := 0
+	for _, s := range ss.shards {
+		n += s.InvalidateTag(tag)
+	}
+	return n
+}
+
+//Delete 删除一个元素，返回元素是否存在
+func (ss *ShardedCacheStore[K, V]) Delete(key K) bool {
+	return ss.shard(key).Delete(key)
+}
+
+//OnEvict 为所有分片注册淘汰回调
+func (ss *ShardedCacheStore[K, V]) OnEvict(fn func(key K, value V, reason EvictReason)) {
+	for _, s := range ss.shards {
+		s.OnEvict(fn)
+	}
+}
@@ -0,0 +1,98 @@
+package cachestore
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedCacheStore(t *testing.T) {
+	ss := NewShardedCacheStore[string, int](4, 10, time.Minute)
+	defer ss.Close()
+	if ss.Cap() != 40 || ss.Shards() != 4 {
+		t.Errorf("cap = %d shards = %d", ss.Cap(), ss.Shards())
+	}
+	for i := 0; i < 20; i++ {
+		ss.Save(fmt.Sprintf("key%d", i), i)
+	}
+	if ss.Len() != 20 {
+		t.Errorf("len = %d, want 20", ss.Len())
+	}
+	for i := 0; i < 20; i++ {
+		if v := ss.Get(fmt.Sprintf("key%d", i)); v != i {
+			t.Errorf("key%d = %d", i, v)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		ss.Save(fmt.Sprintf("more%d", i), i)
+	}
+	if ss.Len() > ss.Cap() {
+		t.Errorf("len %d exceeds cap %d", ss.Len(), ss.Cap())
+	}
+}
+
+func TestShardedCacheStoreHasher(t *testing.T) {
+	ss := NewShardedCacheStore(4, 10, 0, WithHasher[int, int](func(int) uint64 { return 0 }))
+	defer ss.Close()
+	for i := 0; i < 5; i++ {
+		ss.Save(i, i)
+	}
+	if ss.shards[0].Len() != 5 {
+		t.Error("custom hasher should route every key to shard 0")
+	}
+}
+
+func TestShardedCacheStoreNegativeZero(t *testing.T) {
+	//-0与+0是同一个键，应落在同一个分片
+	negZero := math.Copysign(0, -1)
+	if defaultHash(negZero) != defaultHash(0.0) || defaultHash(float32(negZero)) != defaultHash(float32(0)) {
+		t.Fatal("-0 and +0 should hash the same")
+	}
+	ss := NewShardedCacheStore[float64, int](16, 10, 0)
+	defer ss.Close()
+	ss.Save(negZero, 1)
+	if v, ok := ss.GetOK(0); !ok || v != 1 {
+		t.Errorf("get +0 = %d %v, want the value saved with -0", v, ok)
+	}
+}
+
+func benchmarkParallel(b *testing.B, save func(string, int), get func(string) int) {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		save(keys[i], i)
+	}
+	var seq uint32
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddUint32(&seq, 1)) * 7919
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%10 == 0 {
+				save(key, i)
+			} else {
+				get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheStoreParallel(b *testing.B) {
+	cs := NewCacheStore[string, int](8192, time.Minute)
+	defer cs.Close()
+	benchmarkParallel(b, func(k string, v int) { cs.Save(k, v) }, cs.Get)
+}
+
+func BenchmarkShardedCacheStoreParallel(b *testing.B) {
+	for _, n := range []int{4, 16, 64} {
+		b.Run(fmt.Sprintf("shards-%d", n), func(b *testing.B) {
+			ss := NewShardedCacheStore[string, int](n, 8192/n, time.Minute)
+			defer ss.Close()
+			benchmarkParallel(b, func(k string, v int) { ss.Save(k, v) }, ss.Get)
+		})
+	}
+}
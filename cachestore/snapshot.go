@@ -0,0 +1,276 @@
+package cachestore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//快照格式版本
+//版本2在快照头中记录写出时间，版本1的快照仍可读取，但不扣除写出后经过的时间
+const (
+	snapshotVersion   = 2
+	snapshotVersionV1 = 1
+)
+
+//快照头 记录版本、元素数量与写出时间(UnixNano)
+type snapshotHeader struct {
+	Version int
+	Count   int
+	Time    int64
+}
+
+//快照中的一个元素
+//TTL为元素原本的存活时间，Remaining为写出时剩余的存活时间，均为0表示永不过期
+type snapshotEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	TTL       time.Duration
+	Remaining time.Duration
+}
+
+//定期快照配置
+type snapshotConfig struct {
+	path     string
+	interval time.Duration
+	onError  func(error)
+}
+
+//Dump 将所有未过期的元素及其剩余存活时间写入w
+//使用WithCodec指定的编解码器，默认为gob
+func (cs *CacheStore[K, V]) Dump(w io.Writer) error {
+	now := cs.clock.Now()
+	return encodeSnapshot(cs.codec, w, now, cs.snapshotEntries())
+}
+
+//Load 从r中读取Dump写出的元素并保存到缓存仓
+//剩余存活时间扣除快照写出后经过的时间，已过期的元素会被丢弃，同名的键会被覆盖
+func (cs *CacheStore[K, V]) Load(r io.Reader) error {
+	entries, err := decodeSnapshot[K, V](cs.codec, r, cs.clock.Now())
+	if err != nil {
+		return err
+	}
+	cs.loadEntries(entries)
+	return nil
+}
+
+//获取所有未过期的元素及其剩余存活时间
+func (cs *CacheStore[K, V]) snapshotEntries() []snapshotEntry[K, V] {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	now := cs.clock.Now()
+	entries := make([]snapshotEntry[K, V], 0, len(cs.data))
+	for key, item := range cs.data {
+		if item.expired(now) {
+			continue
+		}
+		entry := snapshotEntry[K, V]{Key: key, Value: item.value, TTL: item.ttl}
+		if item.ttl > 0 {
+			entry.Remaining = item.gcTime.Sub(now)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+//保存快照中的元素 已过期的元素被丢弃
+func (cs *CacheStore[K, V]) loadEntries(entries []snapshotEntry[K, V]) {
+	hasTTL := false
+	cs.lock.Lock()
+	now := cs.clock.Now()
+	for _, entry := range entries {
+		if entry.TTL > 0 && entry.Remaining <= 0 {
+			continue
+		}
+		//超出成本预算或未通过准入的元素不会被保存
+		if !cs.saveItem(entry.Key, entry.Value, entry.TTL, now) {
+			continue
+		}
+		if entry.TTL > 0 {
+			hasTTL = true
+			cs.setExpire(cs.data[entry.Key], now.Add(entry.Remaining))
+		}
+	}
+	cs.unlock()
+	if hasTTL {
+		cs.startGC()
+	}
+}
+
+//写出快照头与元素 now为写出时间，应不晚于计算剩余存活时间的时间
+func encodeSnapshot[K comparable, V any](codec Codec, w io.Writer, now time.Time, entries []snapshotEntry[K, V]) error {
+	enc := codec.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Count: len(entries), Time: now.UnixNano()}); err != nil {
+		return err
+	}
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//读取快照头与元素，并从剩余存活时间中扣除写出到now经过的时间
+func decodeSnapshot[K comparable, V any](codec Codec, r io.Reader, now time.Time) ([]snapshotEntry[K, V], error) {
+	dec := codec.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != snapshotVersion && header.Version != snapshotVersionV1 {
+		return nil, fmt.Errorf("cachestore: unsupported snapshot version %d", header.Version)
+	}
+	if header.Count < 0 {
+		return nil, fmt.Errorf("cachestore: invalid snapshot entry count %d", header.Count)
+	}
+	//数量来自输入，不直接按其预分配
+	size := header.Count
+	if size > 1024 {
+		size = 1024
+	}
+	//时钟回拨时不延长剩余存活时间
+	var elapsed time.Duration
+	if header.Time > 0 {
+		if elapsed = now.Sub(time.Unix(0, header.Time)); elapsed < 0 {
+			elapsed = 0
+		}
+	}
+	entries := make([]snapshotEntry[K, V], 0, size)
+	for i := 0; i < header.Count; i++ {
+		var entry snapshotEntry[K, V]
+		if err := dec.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if entry.TTL > 0 {
+			entry.Remaining -= elapsed
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+//SaveSnapshot 将快照写入文件
+//先写入同目录下的临时文件再重命名，避免留下不完整的快照
+func (cs *CacheStore[K, V]) SaveSnapshot(path string) error {
+	return saveSnapshotFile(path, cs.Dump)
+}
+
+//LoadSnapshot 从文件中读取快照
+func (cs *CacheStore[K, V]) LoadSnapshot(path string) error {
+	return loadSnapshotFile(path, cs.Load)
+}
+
+//启动定期快照
+func (cs *CacheStore[K, V]) startSnapshot() {
+	runSnapshot(cs.snapshot, cs.clock, cs.done, &cs.workers, cs.LoadSnapshot, cs.SaveSnapshot)
+}
+
+//Dump 将所有分片中未过期的元素写入w，格式与CacheStore.Dump相同
+func (ss *ShardedCacheStore[K, V]) Dump(w io.Writer) error {
+	now := ss.shards[0].clock.Now()
+	var entries []snapshotEntry[K, V]
+	for _, s := range ss.shards {
+		entries = append(entries, s.snapshotEntries()...)
+	}
+	return encodeSnapshot(ss.shards[0].codec, w, now, entries)
+}
+
+//Load 从r中读取快照，按键的哈希值将元素保存到各自的分片
+//快照可以来自分片数量不同的分片缓存仓，也可以来自CacheStore
+func (ss *ShardedCacheStore[K, V]) Load(r io.Reader) error {
+	entries, err := decodeSnapshot[K, V](ss.shards[0].codec, r, ss.shards[0].clock.Now())
+	if err != nil {
+		return err
+	}
+	routed := make([][]snapshotEntry[K, V], len(ss.shards))
+	for _, entry := range entries {
+		i := ss.hasher(entry.Key) % uint64(len(ss.shards))
+		routed[i] = append(routed[i], entry)
+	}
+	for i, s := range ss.shards {
+		s.loadEntries(routed[i])
+	}
+	return nil
+}
+
+//SaveSnapshot 将所有分片写入同一个快照文件
+func (ss *ShardedCacheStore[K, V]) SaveSnapshot(path string) error {
+	return saveSnapshotFile(path, ss.Dump)
+}
+
+//LoadSnapshot 从快照文件恢复所有分片
+func (ss *ShardedCacheStore[K, V]) LoadSnapshot(path string) error {
+	return loadSnapshotFile(path, ss.Load)
+}
+
+//先写入同目录下的临时文件再重命名
+func saveSnapshotFile(path string, dump func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if err = dump(w); err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func loadSnapshotFile(path string, load func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return load(bufio.NewReader(file))
+}
+
+//运行定期快照
+//启动时先从已有的快照文件恢复，之后每隔interval写入一次，done关闭时写入最后一次快照
+func runSnapshot(conf *snapshotConfig, clock Clock, done <-chan struct{}, workers *sync.WaitGroup, load, save func(string) error) {
+	report := func(err error) {
+		if err != nil && conf.onError != nil {
+			conf.onError(err)
+		}
+	}
+	if err := load(conf.path); !errors.Is(err, os.ErrNotExist) {
+		report(err)
+	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		//间隔小于等于0时只在关闭时写入
+		if conf.interval <= 0 {
+			<-done
+			report(save(conf.path))
+			return
+		}
+		ticker := clock.NewTicker(conf.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				report(save(conf.path))
+			case <-done:
+				report(save(conf.path))
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,188 @@
+package cachestore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testDumpLoad(t *testing.T, codec Codec) {
+	clock := NewManualClock(time.Now())
+	src := NewCacheStore(10, 0, WithClock[string, int](clock), WithCodec[string, int](codec))
+	src.Close()
+	src.Save("forever", 1)
+	src.SaveWithTTL("short", 2, 5*time.Second)
+	src.SaveWithTTL("gone", 3, time.Second)
+	clock.Advance(2 * time.Second)
+
+	var buf bytes.Buffer
+	if err := src.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewCacheStore(10, 0, WithClock[string, int](clock), WithCodec[string, int](codec))
+	dst.Close()
+	if err := dst.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Len() != 2 || dst.Get("forever") != 1 || dst.Get("short") != 2 {
+		t.Errorf("loaded len = %d", dst.Len())
+	}
+	//short剩余3秒
+	clock.Advance(2 * time.Second)
+	if dst.Get("short") != 2 {
+		t.Error("short should keep its remaining ttl")
+	}
+	clock.Advance(2 * time.Second)
+	if dst.Get("short") != 0 {
+		t.Error("short should expire after its remaining ttl")
+	}
+}
+
+func TestDumpLoadGob(t *testing.T) {
+	testDumpLoad(t, GobCodec)
+}
+
+func TestDumpLoadJSON(t *testing.T) {
+	testDumpLoad(t, JSONCodec)
+}
+
+func TestLoadElapsed(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	src := NewCacheStore(10, 0, WithClock[string, int](clock))
+	src.Close()
+	src.SaveWithTTL("short", 1, 10*time.Second)
+	src.SaveWithTTL("gone", 2, 4*time.Second)
+	clock.Advance(2 * time.Second)
+	var buf bytes.Buffer
+	if err := src.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	//写出后停机5秒，short剩余3秒，gone已过期
+	clock.Advance(5 * time.Second)
+	dst := NewCacheStore(10, 0, WithClock[string, int](clock))
+	dst.Close()
+	if err := dst.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Len() != 1 || dst.Get("short") != 1 {
+		t.Fatalf("loaded len = %d, only short should be loaded", dst.Len())
+	}
+	clock.Advance(2 * time.Second)
+	if dst.Get("short") != 1 {
+		t.Error("short should keep the rest of its ttl")
+	}
+	clock.Advance(2 * time.Second)
+	if dst.Get("short") != 0 {
+		t.Error("downtime should be subtracted from the remaining ttl")
+	}
+
+	//版本1的快照没有写出时间，按原剩余存活时间恢复
+	buf.Reset()
+	enc := GobCodec.NewEncoder(&buf)
+	enc.Encode(snapshotHeader{Version: snapshotVersionV1, Count: 1})
+	enc.Encode(&snapshotEntry[string, int]{Key: "old", Value: 3, TTL: time.Minute, Remaining: time.Second})
+	if err := dst.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Get("old") != 3 {
+		t.Error("version 1 snapshot should still be loaded")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cs := NewCacheStore[string, int](10, 0)
+	if err := cs.Load(bytes.NewBufferString("not a snapshot")); err == nil {
+		t.Error("loading garbage should fail")
+	}
+}
+
+func TestWithSnapshot(t *testing.T) {
+	//间隔为0时只在关闭时写入
+	for _, interval := range []time.Duration{time.Minute, 0} {
+		path := filepath.Join(t.TempDir(), "cache.snap")
+		var errs []error
+		onError := func(err error) { errs = append(errs, err) }
+
+		cs := NewCacheStore(10, time.Hour, WithSnapshot[string, string](path, interval, onError))
+		cs.Save("query", "expensive result")
+		cs.Close()
+
+		warm := NewCacheStore(10, time.Hour, WithSnapshot[string, string](path, interval, onError))
+		if warm.Get("query") != "expensive result" {
+			t.Errorf("interval %v: store should be restored from the snapshot file", interval)
+		}
+		warm.Close()
+		if len(errs) > 0 {
+			t.Error(errs)
+		}
+	}
+}
+
+func TestShardedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.snap")
+	onError := func(err error) { t.Error(err) }
+
+	ss := NewShardedCacheStore(4, 100, time.Hour, WithSnapshot[int, int](path, time.Minute, onError))
+	for i := 0; i < 50; i++ {
+		ss.Save(i, i)
+	}
+	ss.Close()
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("%d files in dir, want 1", len(entries))
+	}
+
+	//分片数量不同时按哈希值重新分配
+	warm := NewShardedCacheStore(3, 100, time.Hour, WithSnapshot[int, int](path, time.Minute, onError))
+	defer warm.Close()
+	if warm.Len() != 50 {
+		t.Fatalf("len = %d, want 50", warm.Len())
+	}
+	for i := 0; i < 50; i++ {
+		if v, ok := warm.shard(i).Peek(i); !ok || v != i {
+			t.Fatalf("key %d not restored to its shard", i)
+		}
+	}
+}
+
+func TestLoadRejected(t *testing.T) {
+	src := NewCacheStore[string, []byte](0, 0)
+	src.Close()
+	src.SaveWithTTL("small", make([]byte, 5), time.Hour)
+	src.SaveWithTTL("huge", make([]byte, 50), time.Hour)
+	var buf bytes.Buffer
+	if err := src.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	//超出成本预算的元素被跳过
+	costly := NewCacheStore(0, 0, WithCost(byteCost, 20))
+	costly.Close()
+	if err := costly.Load(bytes.NewReader(snapshot)); err != nil {
+		t.Fatal(err)
+	}
+	if costly.Len() != 1 || costly.Get("small") == nil {
+		t.Errorf("len = %d, only small should be loaded", costly.Len())
+	}
+
+	//未通过准入的元素被跳过
+	admitted := NewCacheStore(1, 0, WithTinyLFU[string, []byte]())
+	admitted.Close()
+	admitted.Save("hot", nil)
+	for i := 0; i < 5; i++ {
+		admitted.Get("hot")
+	}
+	if err := admitted.Load(bytes.NewReader(snapshot)); err != nil {
+		t.Fatal(err)
+	}
+	if admitted.Len() != 1 || admitted.Get("small") != nil || admitted.Get("huge") != nil {
+		t.Errorf("len = %d, hot key should survive the load", admitted.Len())
+	}
+	if admitted.Stats().Rejections != 2 {
+		t.Errorf("rejections = %d, want 2", admitted.Stats().Rejections)
+	}
+}
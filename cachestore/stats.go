@@ -0,0 +1,181 @@
+package cachestore
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//统计计数器 全部使用原子操作，读取时无需持有缓存仓的锁
+type statsCounter struct {
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+	loads       uint64
+	loadErrors  uint64
+	rejections  uint64
+}
+
+//Stats 缓存仓统计快照
+type Stats struct {
+	Hits        uint64        //命中次数
+	Misses      uint64        //未命中次数
+	Evictions   uint64        //因容量不足被淘汰的元素数
+	Expirations uint64        //过期被回收的元素数
+	Loads       uint64        //GetOrLoad执行loader的次数
+	LoadErrors  uint64        //loader返回错误的次数
+	Rejections  uint64        //被准入策略拒绝写入的次数
+	Len         int           //当前存储量
+	Cap         int           //容量
+	Cost        int64         //当前总成本
+	MaxCost     int64         //成本预算
+	HitRatio    float64       //命中率
+	AvgAge      time.Duration //当前元素自写入以来的平均时长
+}
+
+//StatsProvider 可提供统计快照的缓存
+type StatsProvider interface {
+	Stats() Stats
+}
+
+//写入元素时更新写入时间 调用方需持有锁
+func (cs *CacheStore[K, V]) setCreated(item *cacheItem[K, V], now time.Time) {
+	if !item.created.IsZero() {
+		cs.ageSum -= cs.ageOffset(item.created)
+	}
+	item.created = now
+	cs.ageSum += cs.ageOffset(now)
+}
+
+//写入时间相对ageBase的纳秒数
+//以浮点数累加，元素数量再多也不会像UnixNano之和那样溢出
+func (cs *CacheStore[K, V]) ageOffset(t time.Time) float64 {
+	return float64(t.Sub(cs.ageBase))
+}
+
+//Stats 获取缓存仓的统计快照
+func (cs *CacheStore[K, V]) Stats() Stats {
+	s := Stats{
+		Hits:        atomic.LoadUint64(&cs.stats.hits),
+		Misses:      atomic.LoadUint64(&cs.stats.misses),
+		Evictions:   atomic.LoadUint64(&cs.stats.evictions),
+		Expirations: atomic.LoadUint64(&cs.stats.expirations),
+		Loads:       atomic.LoadUint64(&cs.stats.loads),
+		LoadErrors:  atomic.LoadUint64(&cs.stats.loadErrors),
+		Rejections:  atomic.LoadUint64(&cs.stats.rejections),
+		Cap:         cs.cap,
+		MaxCost:     cs.maxCost,
+	}
+	cs.lock.Lock()
+	s.Len = len(cs.data)
+	s.Cost = cs.totalCost
+	if s.Len > 0 {
+		s.AvgAge = time.Duration(cs.ageOffset(cs.clock.Now()) - cs.ageSum/float64(s.Len))
+	}
+	cs.lock.Unlock()
+	s.HitRatio = hitRatio(s.Hits, s.Misses)
+	return s
+}
+
+//Stats 汇总所有分片的统计快照
+func (ss *ShardedCacheStore[K, V]) Stats() Stats {
+	var total Stats
+	var ageSum float64
+	for _, shard := range ss.shards {
+		s := shard.Stats()
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		total.Evictions += s.Evictions
+		total.Expirations += s.Expirations
+		total.Loads += s.Loads
+		total.LoadErrors += s.LoadErrors
+		total.Rejections += s.Rejections
+		total.Len += s.Len
+		total.Cap += s.Cap
+		total.Cost += s.Cost
+		total.MaxCost += s.MaxCost
+		ageSum += float64(s.AvgAge) * float64(s.Len)
+	}
+	if total.Len > 0 {
+		total.AvgAge = time.Duration(ageSum / float64(total.Len))
+	}
+	total.HitRatio = hitRatio(total.Hits, total.Misses)
+	return total
+}
+
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+//PublishExpvar 将缓存统计以name发布到expvar
+//与expvar.Publish相同，重复的name会导致panic
+func PublishExpvar(name string, p StatsProvider) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return p.Stats()
+	}))
+}
+
+//WritePrometheus 以Prometheus文本格式输出多个缓存的统计
+//caches的键作为cache标签的值
+func WritePrometheus(w io.Writer, caches map[string]StatsProvider) error {
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats := make([]Stats, len(names))
+	for i, name := range names {
+		stats[i] = caches[name].Stats()
+	}
+	metrics := []struct {
+		name  string
+		typ   string
+		help  string
+		value func(Stats) float64
+	}{
+		{"cachestore_hits_total", "counter", "Number of cache hits.", func(s Stats) float64 { return float64(s.Hits) }},
+		{"cachestore_misses_total", "counter", "Number of cache misses.", func(s Stats) float64 { return float64(s.Misses) }},
+		{"cachestore_evictions_total", "counter", "Number of entries evicted by capacity.", func(s Stats) float64 { return float64(s.Evictions) }},
+		{"cachestore_expirations_total", "counter", "Number of expired entries collected.", func(s Stats) float64 { return float64(s.Expirations) }},
+		{"cachestore_loads_total", "counter", "Number of loader calls.", func(s Stats) float64 { return float64(s.Loads) }},
+		{"cachestore_load_errors_total", "counter", "Number of loader calls that returned an error.", func(s Stats) float64 { return float64(s.LoadErrors) }},
+		{"cachestore_rejections_total", "counter", "Number of writes rejected by the admission policy.", func(s Stats) float64 { return float64(s.Rejections) }},
+		{"cachestore_entries", "gauge", "Current number of entries.", func(s Stats) float64 { return float64(s.Len) }},
+		{"cachestore_capacity", "gauge", "Configured entry capacity.", func(s Stats) float64 { return float64(s.Cap) }},
+		{"cachestore_cost", "gauge", "Current total cost of entries.", func(s Stats) float64 { return float64(s.Cost) }},
+		{"cachestore_max_cost", "gauge", "Configured cost budget, 0 means unlimited.", func(s Stats) float64 { return float64(s.MaxCost) }},
+		{"cachestore_hit_ratio", "gauge", "Ratio of hits to lookups.", func(s Stats) float64 { return s.HitRatio }},
+		{"cachestore_entry_age_seconds", "gauge", "Average age of current entries.", func(s Stats) float64 { return s.AvgAge.Seconds() }},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ); err != nil {
+			return err
+		}
+		for i, name := range names {
+			if _, err := fmt.Fprintf(w, "%s{cache=\"%s\"} %v\n", m.name, labelEscaper.Replace(name), m.value(stats[i])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//Prometheus标签值转义
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//PrometheusHandler 返回以Prometheus文本格式输出缓存统计的http.Handler
+func PrometheusHandler(caches map[string]StatsProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, caches)
+	})
+}
@@ -0,0 +1,92 @@
+package cachestore
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	clock := NewManualClock(time.Now())
+	cs := NewCacheStore(2, 0, WithClock[string, int](clock))
+	cs.Close()
+	cs.Save("a", 1)
+	clock.Advance(10 * time.Second)
+	cs.Save("b", 2)
+	cs.Get("a")
+	cs.Get("a")
+	cs.Get("b")
+	cs.Get("missing")
+	cs.SaveWithTTL("c", 3, time.Second)
+	clock.Advance(2 * time.Second)
+	cs.doGC()
+	cs.GetOrLoad("d", func(string) (int, error) { return 4, nil })
+	cs.GetOrLoad("e", func(string) (int, error) { return 0, errors.New("fail") })
+
+	s := cs.Stats()
+	if s.Hits != 3 || s.Misses != 3 {
+		t.Errorf("hits = %d misses = %d", s.Hits, s.Misses)
+	}
+	if s.Evictions != 1 || s.Expirations != 1 {
+		t.Errorf("evictions = %d expirations = %d", s.Evictions, s.Expirations)
+	}
+	if s.Loads != 2 || s.LoadErrors != 1 {
+		t.Errorf("loads = %d load errors = %d", s.Loads, s.LoadErrors)
+	}
+	if s.HitRatio != 0.5 {
+		t.Errorf("hit ratio = %v", s.HitRatio)
+	}
+	//剩余b(写入2秒前)与d(刚写入)
+	if s.Len != 2 || s.AvgAge != time.Second {
+		t.Errorf("len = %d avg age = %v", s.Len, s.AvgAge)
+	}
+}
+
+func TestStatsAvgAgeRealClock(t *testing.T) {
+	cs := NewCacheStore[int, int](0, 0)
+	defer cs.Close()
+	for i := 0; i < 100; i++ {
+		cs.Save(i, i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if age := cs.Stats().AvgAge; age < 10*time.Millisecond || age > time.Minute {
+		t.Errorf("avg age = %v", age)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	cs := NewCacheStore[string, int](10, 0)
+	cs.Save("a", 1)
+	cs.Get("a")
+	//expvar不允许重复发布，-count>1时每次使用不同的名称
+	name := fmt.Sprintf("cachestore_test_%d", time.Now().UnixNano())
+	PublishExpvar(name, cs)
+	v := expvar.Get(name)
+	if v == nil || !strings.Contains(v.String(), `"Hits":1`) {
+		t.Errorf("expvar = %v", v)
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	cs := NewCacheStore[string, int](10, 0)
+	cs.Save("a", 1)
+	cs.Get("a")
+	ss := NewShardedCacheStore[string, int](2, 10, 0)
+	resp := httptest.NewRecorder()
+	PrometheusHandler(map[string]StatsProvider{"pages": cs, "users": ss}).ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	body := resp.Body.String()
+	for _, line := range []string{
+		"# TYPE cachestore_hits_total counter",
+		`cachestore_hits_total{cache="pages"} 1`,
+		`cachestore_entries{cache="users"} 0`,
+		`cachestore_capacity{cache="users"} 20`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cachestore
+
+import "time"
+
+//命名空间标签前缀，避免与用户标签冲突
+const namespaceTagPrefix = "\x00ns:"
+
+//SaveTagged 保存一个元素值并为其打上标签
+//标签会替换该元素原有的标签，之后可以通过InvalidateTag按标签批量删除
+//使用Save覆盖已打标签的元素时保留原有标签
+func (cs *CacheStore[K, V]) SaveTagged(key K, value V, tags ...string) bool {
+	return cs.saveTagged(key, value, cs.lifetime, tags)
+}
+
+//SaveTaggedWithTTL 以单独的存活时间保存一个元素值并为其打上标签
+func (cs *CacheStore[K, V]) SaveTaggedWithTTL(key K, value V, ttl time.Duration, tags ...string) bool {
+	return cs.saveTagged(key, value, ttl, tags)
+}
+
+func (cs *CacheStore[K, V]) saveTagged(key K, value V, ttl time.Duration, tags []string) bool {
+	if ttl < 0 {
+		ttl = 0
+	}
+	if ttl > 0 {
+		cs.startGC()
+	}
+	cs.lock.Lock()
+	defer cs.unlock()
+	cs.cancelLoad(key)
+	if !cs.saveItem(key, value, ttl, cs.clock.Now()) {
+		return false
+	}
+	cs.setTags(cs.data[key], tags)
+	return true
+}
+
+//InvalidateTag 删除所有带有该标签的元素，返回删除的个数
+func (cs *CacheStore[K, V]) InvalidateTag(tag string) int {
+	cs.lock.Lock()
+	defer cs.unlock()
+	keys := cs.tags[tag]
+	n := 0
+	for key := range keys {
+		if cs.removeItem(key, EvictDeleted) {
+			n++
+		}
+	}
+	return n
+}
+
+//设置元素的标签并更新标签索引 调用方需持有锁
+func (cs *CacheStore[K, V]) setTags(item *cacheItem[K, V], tags []string) {
+	cs.untagItem(item)
+	if len(tags) == 0 {
+		return
+	}
+	if cs.tags == nil {
+		cs.tags = make(map[string]map[K]struct{})
+	}
+	item.tags = make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys, ok := cs.tags[tag]
+		if !ok {
+			keys = make(map[K]struct{})
+			cs.tags[tag] = keys
+		}
+		if _, dup := keys[item.key]; dup {
+			continue
+		}
+		keys[item.key] = struct{}{}
+		item.tags = append(item.tags, tag)
+	}
+}
+
+//从标签索引中移除元素 调用方需持有锁
+func (cs *CacheStore[K, V]) untagItem(item *cacheItem[K, V]) {
+	for _, tag := range item.tags {
+		if keys, ok := cs.tags[tag]; ok {
+			delete(keys, item.key)
+			if len(keys) == 0 {
+				delete(cs.tags, tag)
+			}
+		}
+	}
+	item.tags = nil
+}
+
+//Namespace 缓存仓中的一组元素，可以整体清除
+//命名空间基于标签实现，键仍与缓存仓中其他元素共用同一键空间
+type Namespace[K comparable, V any] struct {
+	cs  *CacheStore[K, V]
+	tag string
+}
+
+//Namespace 获取名为name的命名空间，同名的命名空间指向同一组元素
+func (cs *CacheStore[K, V]) Namespace(name string) *Namespace[K, V] {
+	return &Namespace[K, V]{cs: cs, tag: namespaceTagPrefix + name}
+}
+
+//Save 在命名空间内保存一个元素值，可以附加其他标签
+func (ns *Namespace[K, V]) Save(key K, value V, tags ...string) bool {
+	return ns.cs.SaveTagged(key, value, append([]string{ns.tag}, tags...)...)
+}
+
+//SaveWithTTL 在命名空间内以单独的存活时间保存一个元素值
+func (ns *Namespace[K, V]) SaveWithTTL(key K, value V, ttl time.Duration, tags ...string) bool {
+	return ns.cs.SaveTaggedWithTTL(key, value, ttl, append([]string{ns.tag}, tags...)...)
+}
+
+//Get 获取命名空间内的一个元素值
+func (ns *Namespace[K, V]) Get(key K) (V, bool) {
+	var res V
+	ns.cs.lock.Lock()
+	defer ns.cs.lock.Unlock()
+	if !ns.contains(key) {
+		return res, false
+	}
+	return ns.cs.getItem(key, ns.cs.clock.Now())
+}
+
+//Delete 删除命名空间内的一个元素
+func (ns *Namespace[K, V]) Delete(key K) bool {
+	ns.cs.lock.Lock()
+	defer ns.cs.unlock()
+	if !ns.contains(key) {
+		return false
+	}
+	return ns.cs.removeItem(key, EvictDeleted)
+}
+
+//Len 获取命名空间内的元素个数
+func (ns *Namespace[K, V]) Len() int {
+	ns.cs.lock.Lock()
+	defer ns.cs.lock.Unlock()
+	return len(ns.cs.tags[ns.tag])
+}
+
+//Flush 删除命名空间内的所有元素，返回删除的个数
+func (ns *Namespace[K, V]) Flush() int {
+	return ns.cs.InvalidateTag(ns.tag)
+}
+
+//键是否属于该命名空间 调用方需持有锁
+func (ns *Namespace[K, V]) contains(key K) bool {
+	_, ok := ns.cs.tags[ns.tag][key]
+	return ok
+}
@@ -0,0 +1,64 @@
+package cachestore
+
+import (
+	"testing"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	cs := NewCacheStore[string, string](10, 0)
+	cs.SaveTagged("user:42:profile", "p", "user:42")
+	cs.SaveTagged("user:42:posts:count", "3", "user:42", "posts")
+	cs.SaveTagged("posts:page:1", "list", "posts")
+	cs.Save("other", "o")
+
+	if n := cs.InvalidateTag("user:42"); n != 2 {
+		t.Errorf("invalidated %d, want 2", n)
+	}
+	if _, ok := cs.GetOK("user:42:profile"); ok {
+		t.Error("tagged entry should be invalidated")
+	}
+	if cs.Len() != 2 {
+		t.Errorf("len = %d, want 2", cs.Len())
+	}
+	//被删除元素的其他标签也应同步清理
+	if n := cs.InvalidateTag("posts"); n != 1 {
+		t.Errorf("invalidated %d, want 1", n)
+	}
+	if len(cs.tags) != 0 {
+		t.Errorf("tag index should be empty, got %v", cs.tags)
+	}
+}
+
+func TestTagsFollowItems(t *testing.T) {
+	cs := NewCacheStore[string, int](1, 0)
+	cs.SaveTagged("a", 1, "t")
+	cs.Save("a", 2)
+	if cs.InvalidateTag("t") != 1 {
+		t.Error("Save should keep the existing tags")
+	}
+	cs.SaveTagged("a", 1, "t")
+	cs.Save("b", 2)
+	if cs.InvalidateTag("t") != 0 || len(cs.tags) != 0 {
+		t.Error("evicted entry should leave the tag index")
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	cs := NewCacheStore[string, int](10, 0)
+	users := cs.Namespace("users")
+	users.Save("1", 1)
+	users.Save("2", 2, "vip")
+	cs.Save("3", 3)
+	if v, ok := users.Get("1"); !ok || v != 1 {
+		t.Error("namespace should find its own key")
+	}
+	if _, ok := users.Get("3"); ok {
+		t.Error("namespace should not see keys outside it")
+	}
+	if cs.Namespace("users").Len() != 2 {
+		t.Error("namespaces with the same name should share entries")
+	}
+	if users.Flush() != 2 || cs.Len() != 1 {
+		t.Errorf("flush should drop the namespace only, len = %d", cs.Len())
+	}
+}
@@ -0,0 +1,232 @@
+package cachestore
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//两级缓存按键加锁的分段数
+const tieredStripes = 64
+
+//TieredCache 两级缓存
+//L1为内存缓存仓，L2为磁盘缓存仓，同一个键只保存在其中一级：
+//L1因容量淘汰的元素降级写入L2，L2命中的元素升级回L1并从L2删除
+//值通过L1配置的编解码器(WithCodec)序列化后写入L2
+//同一个键的写入、删除、升级与降级按键串行执行，降级不会覆盖之后写入的新值
+type TieredCache[K comparable, V any] struct {
+	l1    *CacheStore[K, V]
+	l2    *DiskStore
+	l2TTL time.Duration
+	codec Codec
+	locks [tieredStripes]sync.Mutex //按键的哈希值分段
+
+	demoteLock sync.Mutex
+	demoting   []evictEvent[K, V] //L1已淘汰、等待写入L2的元素，按淘汰顺序排列
+
+	//OnError 接收降级、升级时发生的错误，可为nil
+	OnError func(error)
+}
+
+//NewTieredCache 以l1、l2组成两级缓存
+//l2TTL为降级到L2的元素的存活时间，小于等于0时永不过期
+func NewTieredCache[K comparable, V any](l1 *CacheStore[K, V], l2 *DiskStore, l2TTL time.Duration) *TieredCache[K, V] {
+	tc := &TieredCache[K, V]{l1: l1, l2: l2, l2TTL: l2TTL, codec: l1.codec}
+	//淘汰时在L1的锁内登记，之后的写入与删除能看到尚未降级的元素
+	l1.lock.Lock()
+	l1.evictHook = func(key K, value V, reason EvictReason) {
+		if reason == EvictCapacity {
+			tc.demoteLock.Lock()
+			tc.demoting = append(tc.demoting, evictEvent[K, V]{key: key, value: value, reason: reason})
+			tc.demoteLock.Unlock()
+		}
+	}
+	l1.lock.Unlock()
+	l1.OnEvict(func(key K, value V, reason EvictReason) {
+		if reason == EvictCapacity {
+			tc.demotePending()
+		}
+	})
+	return tc
+}
+
+//L1 获取内存缓存仓
+func (tc *TieredCache[K, V]) L1() *CacheStore[K, V] {
+	return tc.l1
+}
+
+//L2 获取磁盘缓存仓
+func (tc *TieredCache[K, V]) L2() *DiskStore {
+	return tc.l2
+}
+
+//Save 保存一个元素值到L1，并删除L2中的旧值
+func (tc *TieredCache[K, V]) Save(key K, value V) bool {
+	lock := tc.lock(key)
+	lock.Lock()
+	tc.dropPending(key)
+	ok := tc.l1.Save(key, value)
+	if _, err := tc.l2.Delete(diskKey(key)); err != nil {
+		tc.report(err)
+	}
+	lock.Unlock()
+	tc.demotePending()
+	return ok
+}
+
+//Get 获取一个元素值，L1未命中时读取L2并升级到L1
+//L1拒绝写入(准入策略或成本限制)时元素仍留在L2
+func (tc *TieredCache[K, V]) Get(key K) (V, bool) {
+	if v, ok := tc.l1.GetOK(key); ok {
+		return v, true
+	}
+	lock := tc.lock(key)
+	lock.Lock()
+	defer tc.demotePending()
+	defer lock.Unlock()
+	//加锁后重新检查，期间可能有写入或升级
+	if v, ok := tc.l1.GetOK(key); ok {
+		return v, true
+	}
+	if v, ok := tc.pendingValue(key); ok {
+		return v, true
+	}
+	var res V
+	dk := diskKey(key)
+	data, ok, err := tc.l2.Get(dk)
+	if err != nil {
+		tc.report(err)
+		return res, false
+	}
+	if !ok {
+		return res, false
+	}
+	if err := tc.codec.NewDecoder(bytes.NewReader(data)).Decode(&res); err != nil {
+		tc.report(err)
+		return res, false
+	}
+	//L1拒绝写入时保留在L2中，避免两级都丢失该元素
+	if tc.l1.Save(key, res) {
+		if _, err := tc.l2.Delete(dk); err != nil {
+			tc.report(err)
+		}
+	}
+	return res, true
+}
+
+//Delete 从两级缓存中删除一个元素
+func (tc *TieredCache[K, V]) Delete(key K) bool {
+	lock := tc.lock(key)
+	lock.Lock()
+	defer tc.demotePending()
+	defer lock.Unlock()
+	ok := tc.l1.Delete(key)
+	pending := tc.dropPending(key)
+	found, err := tc.l2.Delete(diskKey(key))
+	if err != nil {
+		tc.report(err)
+	}
+	return ok || pending || found
+}
+
+//Close 关闭两级缓存
+func (tc *TieredCache[K, V]) Close() error {
+	tc.l1.Close()
+	return tc.l2.Close()
+}
+
+//获取键所在分段的锁
+func (tc *TieredCache[K, V]) lock(key K) *sync.Mutex {
+	return &tc.locks[tc.l1.hasher(key)%tieredStripes]
+}
+
+//将等待中的元素按淘汰顺序写入L2
+//键所在分段被其他操作持有时跳过，由持有者释放锁后再处理；同一分段内保持淘汰顺序
+func (tc *TieredCache[K, V]) demotePending() {
+	for {
+		tc.demoteLock.Lock()
+		var busy [tieredStripes]bool
+		found := -1
+		var lock *sync.Mutex
+		for i, e := range tc.demoting {
+			stripe := tc.l1.hasher(e.key) % tieredStripes
+			if busy[stripe] {
+				continue
+			}
+			if tc.locks[stripe].TryLock() {
+				found, lock = i, &tc.locks[stripe]
+				break
+			}
+			busy[stripe] = true
+		}
+		if found < 0 {
+			tc.demoteLock.Unlock()
+			return
+		}
+		e := tc.demoting[found]
+		tc.demoting = append(tc.demoting[:found], tc.demoting[found+1:]...)
+		tc.demoteLock.Unlock()
+		//L1中已有该键说明淘汰后又写入了新值
+		if _, ok := tc.l1.Peek(e.key); !ok {
+			tc.demote(e.key, e.value)
+		}
+		lock.Unlock()
+	}
+}
+
+//丢弃键等待降级的旧值，返回是否存在 调用方需持有键所在分段的锁
+func (tc *TieredCache[K, V]) dropPending(key K) bool {
+	tc.demoteLock.Lock()
+	defer tc.demoteLock.Unlock()
+	found := false
+	rest := tc.demoting[:0]
+	for _, e := range tc.demoting {
+		if e.key == key {
+			found = true
+			continue
+		}
+		rest = append(rest, e)
+	}
+	tc.demoting = rest
+	return found
+}
+
+//获取键最近一次等待降级的值 调用方需持有键所在分段的锁
+func (tc *TieredCache[K, V]) pendingValue(key K) (V, bool) {
+	tc.demoteLock.Lock()
+	defer tc.demoteLock.Unlock()
+	for i := len(tc.demoting) - 1; i >= 0; i-- {
+		if tc.demoting[i].key == key {
+			return tc.demoting[i].value, true
+		}
+	}
+	var res V
+	return res, false
+}
+
+//将L1淘汰的元素写入L2
+func (tc *TieredCache[K, V]) demote(key K, value V) {
+	var buf bytes.Buffer
+	if err := tc.codec.NewEncoder(&buf).Encode(&value); err != nil {
+		tc.report(err)
+		return
+	}
+	if err := tc.l2.Put(diskKey(key), buf.Bytes(), tc.l2TTL); err != nil {
+		tc.report(err)
+	}
+}
+
+func (tc *TieredCache[K, V]) report(err error) {
+	if tc.OnError != nil {
+		tc.OnError(err)
+	}
+}
+
+//磁盘中使用的键 字符串键保持原样，其他类型按%#v格式化
+func diskKey[K comparable](key K) string {
+	if s, ok := any(key).(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%#v", key)
+}
@@ -0,0 +1,108 @@
+package cachestore
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTieredCache(t *testing.T) {
+	l2, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1 := NewCacheStore(2, 0, WithLRU[string, []string]())
+	tc := NewTieredCache(l1, l2, 0)
+	tc.OnError = func(err error) { t.Error(err) }
+	defer tc.Close()
+
+	tc.Save("a", []string{"artifact", "a"})
+	tc.Save("b", []string{"artifact", "b"})
+	tc.Save("c", []string{"artifact", "c"})
+	//a被L1淘汰后降级到L2
+	if _, ok := l1.Peek("a"); ok {
+		t.Fatal("a should be evicted from l1")
+	}
+	if l2.Len() != 1 {
+		t.Fatalf("l2 len = %d, want 1", l2.Len())
+	}
+	//L2命中后升级回L1，并使b降级
+	v, ok := tc.Get("a")
+	if !ok || len(v) != 2 || v[1] != "a" {
+		t.Fatalf("a = %v %v", v, ok)
+	}
+	if _, ok := l1.Peek("a"); !ok {
+		t.Error("a should be promoted to l1")
+	}
+	if _, ok, _ := l2.Get("b"); !ok {
+		t.Error("b should be demoted to l2")
+	}
+	if _, ok, _ := l2.Get("a"); ok {
+		t.Error("promoted a should leave l2")
+	}
+	if !tc.Delete("b") {
+		t.Error("delete should find b in l2")
+	}
+	if _, ok := tc.Get("b"); ok {
+		t.Error("b should be deleted from both tiers")
+	}
+}
+
+func TestTieredCacheRejectedPromotion(t *testing.T) {
+	l2, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1 := NewCacheStore(0, 0, WithCost(func(_ string, v []string) int64 { return int64(len(v)) }, 2))
+	tc := NewTieredCache(l1, l2, 0)
+	tc.OnError = func(err error) { t.Error(err) }
+	defer tc.Close()
+
+	//成本超出L1上限的元素无法升级，读取后仍应留在L2
+	tc.demote("big", []string{"a", "b", "c"})
+	for i := 0; i < 2; i++ {
+		if v, ok := tc.Get("big"); !ok || len(v) != 3 {
+			t.Fatalf("get %d: big = %v %v", i, v, ok)
+		}
+	}
+	if _, ok, _ := l2.Get("big"); !ok {
+		t.Error("rejected promotion should keep the value in l2")
+	}
+}
+
+func TestTieredCacheConcurrent(t *testing.T) {
+	l2, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1 := NewCacheStore(1, 0, WithLRU[string, int]())
+	//先注册的回调先执行，放大淘汰与降级之间的间隔
+	l1.OnEvict(func(string, int, EvictReason) { time.Sleep(100 * time.Microsecond) })
+	tc := NewTieredCache(l1, l2, 0)
+	tc.OnError = func(err error) { t.Error(err) }
+	defer tc.Close()
+
+	//并发写入、读取与淘汰后，同一个键只保存在其中一级，且读到的是最后写入的值
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			for i := 1; i <= 200; i++ {
+				tc.Save(key, i)
+				tc.Get("a")
+			}
+		}(key)
+	}
+	wg.Wait()
+	for _, key := range []string{"a", "b", "c"} {
+		_, inL1 := l1.Peek(key)
+		_, inL2, _ := l2.Get(key)
+		if inL1 && inL2 {
+			t.Errorf("%s is kept in both tiers", key)
+		}
+		if v, ok := tc.Get(key); !ok || v != 200 {
+			t.Errorf("%s = %d %v, want 200", key, v, ok)
+		}
+	}
+}
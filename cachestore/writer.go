@@ -0,0 +1,291 @@
+package cachestore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//直写模式按键加锁的分段数
+const writeThroughStripes = 64
+
+//写回模式的默认参数
+const (
+	writeBehindRetries = 3
+	writeBehindBackoff = 100 * time.Millisecond
+)
+
+//ErrWriterClosed 写回适配器已关闭
+var ErrWriterClosed = errors.New("cachestore: writer closed")
+
+//WriteOp 一次对后端存储的写操作
+//Delete为true时删除Key，否则写入Key与Value
+type WriteOp[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Delete bool
+}
+
+//Writer 缓存仓的后端存储
+//Write按顺序执行一批写操作，返回错误时整批会被重试
+type Writer[K comparable, V any] interface {
+	Write(ops []WriteOp[K, V]) error
+}
+
+//WriteThrough 直写适配器
+//写入与删除先同步作用于后端存储，成功后再更新缓存仓；后端失败时缓存仓保持不变
+//同一个键的写入与删除按键串行执行，后端存储与缓存仓的最终值一致
+type WriteThrough[K comparable, V any] struct {
+	cs    *CacheStore[K, V]
+	w     Writer[K, V]
+	locks [writeThroughStripes]sync.Mutex //按键的哈希值分段
+}
+
+//NewWriteThrough 以w作为cs的后端存储，使用直写模式
+func NewWriteThrough[K comparable, V any](cs *CacheStore[K, V], w Writer[K, V]) *WriteThrough[K, V] {
+	return &WriteThrough[K, V]{cs: cs, w: w}
+}
+
+//Store 获取缓存仓
+func (wt *WriteThrough[K, V]) Store() *CacheStore[K, V] {
+	return wt.cs
+}
+
+//Save 写入后端存储后保存到缓存仓
+func (wt *WriteThrough[K, V]) Save(key K, value V) error {
+	return wt.SaveWithTTL(key, value, wt.cs.lifetime)
+}
+
+//SaveWithTTL 写入后端存储后以单独的存活时间保存到缓存仓
+func (wt *WriteThrough[K, V]) SaveWithTTL(key K, value V, ttl time.Duration) error {
+	lock := wt.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if err := wt.w.Write([]WriteOp[K, V]{{Key: key, Value: value}}); err != nil {
+		return err
+	}
+	wt.cs.SaveWithTTL(key, value, ttl)
+	return nil
+}
+
+//Get 从缓存仓获取一个元素值
+func (wt *WriteThrough[K, V]) Get(key K) (V, bool) {
+	return wt.cs.GetOK(key)
+}
+
+//Delete 从后端存储删除后从缓存仓删除
+func (wt *WriteThrough[K, V]) Delete(key K) error {
+	lock := wt.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	if err := wt.w.Write([]WriteOp[K, V]{{Key: key, Delete: true}}); err != nil {
+		return err
+	}
+	wt.cs.Delete(key)
+	return nil
+}
+
+//获取键所在分段的锁
+func (wt *WriteThrough[K, V]) lock(key K) *sync.Mutex {
+	return &wt.locks[wt.cs.hasher(key)%writeThroughStripes]
+}
+
+//WriteBehind 写回适配器
+//写入与删除立即作用于缓存仓，并记为脏数据，按时间间隔或脏数据数量批量写入后端存储
+//同一个键在两次写出之间的多次修改只写出最后一次；写出失败时按退避重试，
+//重试耗尽后仍未写出的修改保留到下一次写出；Close时会进行最后一次写出
+type WriteBehind[K comparable, V any] struct {
+	cs        *CacheStore[K, V]
+	w         Writer[K, V]
+	batchSize int
+
+	//Retries 单次写出失败后的重试次数，默认为3
+	//RetryBackoff 第一次重试前的等待时间，之后每次加倍，默认为100ms
+	//OnError 接收重试耗尽后的写出错误，可为nil
+	//以上字段需在第一次写入前设置
+	Retries      int
+	RetryBackoff time.Duration
+	OnError      func(error)
+
+	lock   sync.Mutex
+	dirty  map[K]WriteOp[K, V]
+	order  []K //脏数据的键按首次修改的顺序排列
+	closed bool
+
+	flushLock sync.Mutex //同一时间只有一次写出，保证写出顺序
+	kick      chan struct{}
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+//NewWriteBehind 以w作为cs的后端存储，使用写回模式
+//interval为定时写出的间隔，小于等于0时只在脏数据达到batchSize、调用Flush或Close时写出
+//batchSize为触发写出的脏数据数量，小于等于0时只按时间间隔写出
+func NewWriteBehind[K comparable, V any](cs *CacheStore[K, V], w Writer[K, V], interval time.Duration, batchSize int) *WriteBehind[K, V] {
+	wb := &WriteBehind[K, V]{
+		cs:           cs,
+		w:            w,
+		batchSize:    batchSize,
+		Retries:      writeBehindRetries,
+		RetryBackoff: writeBehindBackoff,
+		dirty:        make(map[K]WriteOp[K, V]),
+		kick:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	var ticker Ticker
+	if interval > 0 {
+		ticker = cs.clock.NewTicker(interval)
+	}
+	wb.wg.Add(1)
+	go wb.run(ticker)
+	return wb
+}
+
+//Store 获取缓存仓
+func (wb *WriteBehind[K, V]) Store() *CacheStore[K, V] {
+	return wb.cs
+}
+
+//Save 保存到缓存仓，并记为待写出
+func (wb *WriteBehind[K, V]) Save(key K, value V) error {
+	return wb.SaveWithTTL(key, value, wb.cs.lifetime)
+}
+
+//SaveWithTTL 以单独的存活时间保存到缓存仓，并记为待写出
+func (wb *WriteBehind[K, V]) SaveWithTTL(key K, value V, ttl time.Duration) error {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+	if wb.closed {
+		return ErrWriterClosed
+	}
+	wb.cs.SaveWithTTL(key, value, ttl)
+	wb.mark(WriteOp[K, V]{Key: key, Value: value})
+	return nil
+}
+
+//Get 从缓存仓获取一个元素值
+func (wb *WriteBehind[K, V]) Get(key K) (V, bool) {
+	return wb.cs.GetOK(key)
+}
+
+//Delete 从缓存仓删除，并记为待删除
+func (wb *WriteBehind[K, V]) Delete(key K) error {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+	if wb.closed {
+		return ErrWriterClosed
+	}
+	wb.cs.Delete(key)
+	wb.mark(WriteOp[K, V]{Key: key, Delete: true})
+	return nil
+}
+
+//Pending 获取待写出的修改数量
+func (wb *WriteBehind[K, V]) Pending() int {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+	return len(wb.dirty)
+}
+
+//记录一个修改，达到批量大小时通知后台写出 调用方需持有锁
+func (wb *WriteBehind[K, V]) mark(op WriteOp[K, V]) {
+	if _, ok := wb.dirty[op.Key]; !ok {
+		wb.order = append(wb.order, op.Key)
+	}
+	wb.dirty[op.Key] = op
+	if wb.batchSize > 0 && len(wb.dirty) >= wb.batchSize {
+		select {
+		case wb.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+//Flush 立即写出所有待写出的修改，失败时按配置重试
+func (wb *WriteBehind[K, V]) Flush() error {
+	wb.flushLock.Lock()
+	defer wb.flushLock.Unlock()
+	wb.lock.Lock()
+	if len(wb.dirty) == 0 {
+		wb.lock.Unlock()
+		return nil
+	}
+	ops := make([]WriteOp[K, V], 0, len(wb.order))
+	for _, key := range wb.order {
+		ops = append(ops, wb.dirty[key])
+	}
+	wb.dirty = make(map[K]WriteOp[K, V])
+	wb.order = nil
+	retries, backoff := wb.Retries, wb.RetryBackoff
+	wb.lock.Unlock()
+
+	err := wb.w.Write(ops)
+	for i := 0; err != nil && i < retries; i++ {
+		select {
+		case <-time.After(backoff):
+		case <-wb.done:
+			//关闭时不再等待退避，直接重试
+		}
+		backoff *= 2
+		err = wb.w.Write(ops)
+	}
+	if err != nil {
+		wb.requeue(ops)
+	}
+	return err
+}
+
+//将写出失败的修改放回，写出期间已有更新的键保留新的修改
+func (wb *WriteBehind[K, V]) requeue(ops []WriteOp[K, V]) {
+	wb.lock.Lock()
+	defer wb.lock.Unlock()
+	var order []K
+	for _, op := range ops {
+		if _, ok := wb.dirty[op.Key]; !ok {
+			wb.dirty[op.Key] = op
+			order = append(order, op.Key)
+		}
+	}
+	wb.order = append(order, wb.order...)
+}
+
+//Close 停止后台写出并写出剩余的修改，不会关闭缓存仓
+func (wb *WriteBehind[K, V]) Close() error {
+	wb.lock.Lock()
+	if wb.closed {
+		wb.lock.Unlock()
+		return nil
+	}
+	wb.closed = true
+	close(wb.done)
+	wb.lock.Unlock()
+	wb.wg.Wait()
+	return wb.Flush()
+}
+
+//后台写出 按时间间隔或批量大小触发
+func (wb *WriteBehind[K, V]) run(ticker Ticker) {
+	defer wb.wg.Done()
+	var tick <-chan time.Time
+	if ticker != nil {
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+	for {
+		select {
+		case <-tick:
+		case <-wb.kick:
+		case <-wb.done:
+			return
+		}
+		if err := wb.Flush(); err != nil {
+			wb.report(err)
+		}
+	}
+}
+
+func (wb *WriteBehind[K, V]) report(err error) {
+	if wb.OnError != nil {
+		wb.OnError(err)
+	}
+}
@@ -0,0 +1,240 @@
+package cachestore
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+//记录写操作的后端存储 failures次之前的写出都会失败
+type memWriter struct {
+	lock     sync.Mutex
+	data     map[string]int
+	batches  [][]WriteOp[string, int]
+	failures int
+}
+
+func newMemWriter() *memWriter {
+	return &memWriter{data: make(map[string]int)}
+}
+
+func (m *memWriter) Write(ops []WriteOp[string, int]) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("backend unavailable")
+	}
+	m.batches = append(m.batches, ops)
+	for _, op := range ops {
+		if op.Delete {
+			delete(m.data, op.Key)
+		} else {
+			m.data[op.Key] = op.Value
+		}
+	}
+	return nil
+}
+
+func (m *memWriter) get(key string) (int, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	v, ok := m.data[key]
+	return v, ok
+}
+
+func (m *memWriter) setFailures(n int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.failures = n
+}
+
+func (m *memWriter) batchCount() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.batches)
+}
+
+func TestWriteThrough(t *testing.T) {
+	m := newMemWriter()
+	wt := NewWriteThrough[string, int](NewCacheStore[string, int](0, 0), m)
+	if err := wt.Save("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.get("a"); !ok || v != 1 {
+		t.Fatal("save should persist synchronously")
+	}
+	m.setFailures(1)
+	if err := wt.Save("a", 2); err == nil {
+		t.Fatal("backend error should be returned")
+	}
+	if v, _ := wt.Get("a"); v != 1 {
+		t.Fatalf("cache should keep the persisted value, got %d", v)
+	}
+	if err := wt.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.get("a"); ok {
+		t.Fatal("delete should persist")
+	}
+	if _, ok := wt.Get("a"); ok {
+		t.Fatal("delete should remove from cache")
+	}
+}
+
+//写入后随机等待才返回的后端存储，使并发写入在更新缓存仓之前交错
+type slowWriter struct {
+	*memWriter
+}
+
+func (s slowWriter) Write(ops []WriteOp[string, int]) error {
+	err := s.memWriter.Write(ops)
+	time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+	return err
+}
+
+func TestWriteThroughConcurrent(t *testing.T) {
+	m := newMemWriter()
+	wt := NewWriteThrough[string, int](NewCacheStore[string, int](0, 0), slowWriter{m})
+	for round := 0; round < 50; round++ {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(v int) {
+				defer wg.Done()
+				if v == 0 {
+					wt.Delete("k")
+				} else {
+					wt.Save("k", v)
+				}
+			}(i)
+		}
+		wg.Wait()
+		backend, inBackend := m.get("k")
+		cached, inCache := wt.Get("k")
+		if inBackend != inCache || backend != cached {
+			t.Fatalf("round %d: backend = %d %v, cache = %d %v", round, backend, inBackend, cached, inCache)
+		}
+	}
+}
+
+func TestWriteBehindBatchSize(t *testing.T) {
+	m := newMemWriter()
+	wb := NewWriteBehind[string, int](NewCacheStore[string, int](0, 0), m, 0, 3)
+	defer wb.Close()
+	wb.Save("a", 1)
+	wb.Save("a", 2)
+	wb.Save("b", 1)
+	if _, ok := m.get("a"); ok {
+		t.Fatal("write-behind should not persist before the batch is full")
+	}
+	if v, _ := wb.Get("a"); v != 2 {
+		t.Fatal("cache should be updated immediately")
+	}
+	wb.Delete("c")
+	waitFor(t, func() bool { return m.batchCount() == 1 })
+	m.lock.Lock()
+	batch := m.batches[0]
+	m.lock.Unlock()
+	if len(batch) != 3 || batch[0].Key != "a" || batch[0].Value != 2 || !batch[2].Delete {
+		t.Fatalf("batch = %+v, want coalesced writes in order", batch)
+	}
+}
+
+func TestWriteBehindInterval(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	m := newMemWriter()
+	cs := NewCacheStore(0, 0, WithClock[string, int](clock))
+	wb := NewWriteBehind[string, int](cs, m, time.Second, 0)
+	defer wb.Close()
+	wb.Save("a", 1)
+	clock.Advance(time.Second)
+	waitFor(t, func() bool { return m.batchCount() == 1 })
+	if wb.Pending() != 0 {
+		t.Fatalf("pending = %d after flush", wb.Pending())
+	}
+}
+
+func TestWriteBehindRetry(t *testing.T) {
+	m := newMemWriter()
+	wb := NewWriteBehind[string, int](NewCacheStore[string, int](0, 0), m, 0, 0)
+	wb.RetryBackoff = time.Millisecond
+
+	wb.Save("a", 1)
+	m.setFailures(2)
+	if err := wb.Flush(); err != nil {
+		t.Fatalf("flush should succeed after retries: %v", err)
+	}
+	if v, _ := m.get("a"); v != 1 {
+		t.Fatal("value not persisted after retry")
+	}
+
+	//重试耗尽后修改保留，较新的修改优先
+	wb.Save("a", 2)
+	wb.Save("b", 2)
+	m.setFailures(10)
+	if err := wb.Flush(); err == nil {
+		t.Fatal("flush should fail when retries are exhausted")
+	}
+	if wb.Pending() != 2 {
+		t.Fatalf("pending = %d, want 2", wb.Pending())
+	}
+	m.setFailures(0)
+	wb.Save("a", 3)
+
+	//Close进行最后一次写出
+	if err := wb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := m.get("a"); a != 3 {
+		t.Fatalf("a = %d, want 3", a)
+	}
+	if b, _ := m.get("b"); b != 2 {
+		t.Fatalf("b = %d, want 2", b)
+	}
+	if err := wb.Save("c", 1); err != ErrWriterClosed {
+		t.Fatalf("save after close: %v", err)
+	}
+}
+
+func TestDirWriter(t *testing.T) {
+	dir := t.TempDir()
+	dw, err := NewDirWriter[int, string](dir, JSONCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := []WriteOp[int, string]{
+		{Key: 1, Value: "one"},
+		{Key: 2, Value: "two"},
+		{Key: 1, Delete: true},
+		{Key: 3, Delete: true},
+	}
+	if err := dw.Write(ops); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dw.Load(1); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("deleted key: %v", err)
+	}
+	if v, err := dw.Load(2); err != nil || v != "two" {
+		t.Fatalf("v = %q err = %v", v, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("%d files in dir, want 1", len(entries))
+	}
+
+	//与写回适配器和GetOrLoad配合使用
+	cs := NewCacheStore[int, string](0, 0)
+	wb := NewWriteBehind[int, string](cs, dw, 0, 0)
+	wb.Save(5, "five")
+	if err := wb.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cs.Clear()
+	if v, err := cs.GetOrLoad(5, dw.Load); err != nil || v != "five" {
+		t.Fatalf("v = %q err = %v", v, err)
+	}
+}
@@ -0,0 +1,74 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+)
+
+//DefaultIDBits session id默认的随机位数
+const DefaultIDBits = 128
+
+//生成的id与仓库中已有的session冲突时的最多尝试次数
+const maxIDAttempts = 5
+
+//ErrIDCollision 多次生成的id都与已有的session冲突
+var ErrIDCollision = errors.New("sessions: could not generate a unique session id")
+
+//IDGenerator session id生成器接口
+//生成的id会直接写入cookie，应只包含cookie值允许的字符
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+//RandomIDGenerator 基于crypto/rand的id生成器
+//Bits为随机位数，按字节向上取整，小于等于0时使用DefaultIDBits
+//id使用不带填充的URL安全base64编码
+type RandomIDGenerator struct {
+	Bits int
+}
+
+//NewRandomIDGenerator 生成一个指定随机位数的id生成器
+func NewRandomIDGenerator(bits int) *RandomIDGenerator {
+	return &RandomIDGenerator{Bits: bits}
+}
+
+//NewID 生成一个新的id
+func (g *RandomIDGenerator) NewID() (string, error) {
+	bits := g.Bits
+	if bits <= 0 {
+		bits = DefaultIDBits
+	}
+	buf := make([]byte, (bits+7)/8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+//SetIDGenerator 设置默认管理器的session id生成器 来取代默认的生成器
+func SetIDGenerator(g IDGenerator) {
+	defaultManager.ids = g
+}
+
+//Exister 可以检查session id是否已被占用的仓库
+//生成session id时用于检查冲突，未实现时不检查
+type Exister interface {
+	Exists(id string) bool
+}
+
+//生成一个仓库中不存在的session id
+//store为nil或未实现Exister时不检查冲突
+func createSessionID(gen IDGenerator, store Storage) (string, error) {
+	ex, _ := store.(Exister)
+	for i := 0; i < maxIDAttempts; i++ {
+		id, err := gen.NewID()
+		if err != nil {
+			return "", err
+		}
+		if ex == nil || !ex.Exists(id) {
+			return id, nil
+		}
+	}
+	return "", ErrIDCollision
+}
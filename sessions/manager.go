@@ -0,0 +1,174 @@
+package sessions
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//默认的cookie名称
+const defaultCookieName = "GO_WEBSESS"
+
+//ErrNoSession 请求中没有session cookie
+var ErrNoSession = errors.New("no session")
+
+//Manager session管理器
+//持有自己的仓库、cookie名称、cookie属性、id生成器以及仓库的回收生命周期，
+//同一个应用中可以用多个Manager使用不同的session配置
+type Manager struct {
+	storage    Storage
+	cookieName string
+	options    CookieOptions
+	ids        IDGenerator
+	grace      time.Duration
+	gcOnce     sync.Once
+}
+
+//ManagerOption Manager的可选配置
+type ManagerOption func(*Manager)
+
+//WithCookieName 设置存放session id的cookie名称，默认为GO_WEBSESS
+func WithCookieName(name string) ManagerOption {
+	return func(m *Manager) {
+		m.cookieName = name
+	}
+}
+
+//WithCookieOptions 设置新session的cookie属性
+//默认路径为/，存活时间为3600秒，只经由http传输
+func WithCookieOptions(opts CookieOptions) ManagerOption {
+	return func(m *Manager) {
+		m.options = opts
+	}
+}
+
+//WithIDGenerator 设置session id生成器，默认为128位的RandomIDGenerator
+func WithIDGenerator(g IDGenerator) ManagerOption {
+	return func(m *Manager) {
+		m.ids = g
+	}
+}
+
+//NewManager 生成一个使用store的session管理器，并启动仓库的过期回收
+func NewManager(store Storage, opts ...ManagerOption) *Manager {
+	m := newManager(store)
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.bindStorage()
+	m.startGC()
+	return m
+}
+
+//生成使用默认配置的管理器 NewManager与默认管理器共用
+func newManager(store Storage) *Manager {
+	return &Manager{
+		storage:    store,
+		cookieName: defaultCookieName,
+		options:    CookieOptions{Path: "/", MaxAge: 3600, HTTPOnly: true},
+		ids:        NewRandomIDGenerator(DefaultIDBits),
+	}
+}
+
+//需要知道cookie名称的仓库，如从请求中读取分块cookie的CookieStorage
+type cookieNamer interface {
+	setCookieName(name string)
+}
+
+//除了主cookie外还需要让客户端删除其他cookie的仓库
+type cookieExpirer interface {
+	expireCookies(w http.ResponseWriter, sess *Session)
+}
+
+//将cookie名称告知仓库 设置仓库或cookie名称后调用
+func (m *Manager) bindStorage() {
+	if n, ok := m.storage.(cookieNamer); ok {
+		n.setCookieName(m.cookieName)
+	}
+}
+
+//启动仓库的过期回收 只会启动一次
+func (m *Manager) startGC() {
+	m.gcOnce.Do(func() {
+		if m.storage != nil {
+			m.storage.GC()
+		}
+	})
+}
+
+//Storage 获取session仓库
+func (m *Manager) Storage() Storage {
+	return m.storage
+}
+
+//CookieName 获取存放session id的cookie名称
+func (m *Manager) CookieName() string {
+	return m.cookieName
+}
+
+//New 生成一个新的session，调用Save后才会写入仓库和cookie
+func (m *Manager) New() (*Session, error) {
+	opts := m.options
+	return m.newSession(&opts)
+}
+
+func (m *Manager) newSession(opts *CookieOptions) (*Session, error) {
+	id, err := createSessionID(m.ids, m.storage)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		ID:         id,
+		Options:    opts,
+		storage:    m.storage,
+		cookieName: m.cookieName,
+		Values:     make(map[interface{}]interface{}),
+		IsNew:      true,
+		ActTime:    time.Now().Unix(),
+	}, nil
+}
+
+//Get 从请求的cookie中获取session
+func (m *Manager) Get(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(m.cookieName)
+	if err != nil {
+		return nil, ErrNoSession
+	}
+	sess, err := m.storage.Get(r, cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+	sess.storage = m.storage
+	sess.cookieName = m.cookieName
+	return sess, nil
+}
+
+//Destroy 从仓库中删除session，并让客户端的cookie失效
+func (m *Manager) Destroy(w http.ResponseWriter, sess *Session) {
+	m.storage.Del(sess.ID)
+	sess.Options.MaxAge = -1
+	sess.cookieName = m.cookieName
+	http.SetCookie(w, NewCookie(sess))
+	if e, ok := m.storage.(cookieExpirer); ok {
+		e.expireCookies(w, sess)
+	}
+}
+
+//Close 停止仓库的过期回收
+//仓库实现了io.Closer时调用其Close
+func (m *Manager) Close() error {
+	if c, ok := m.storage.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+//包级函数使用的默认管理器
+var defaultManager = newManager(nil)
+
+//Default 获取包级函数使用的默认管理器
+func Default() *Manager {
+	return defaultManager
+}
@@ -0,0 +1,117 @@
+package sessions
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+//请求context中存放session的键
+type contextKey struct{}
+
+//一个请求内的session 首次访问时才从仓库读取或新建
+type requestSession struct {
+	m    *Manager
+	r    *http.Request
+	once sync.Once
+	sess *Session
+}
+
+func (rs *requestSession) get() *Session {
+	rs.once.Do(func() {
+		if sess, err := rs.m.Get(rs.r); err == nil {
+			rs.sess = sess
+			return
+		}
+		if sess, err := rs.m.New(); err == nil {
+			rs.sess = sess
+		}
+	})
+	return rs.sess
+}
+
+//保存被修改过的session 未访问或未修改时不做任何事
+func (rs *requestSession) save(w http.ResponseWriter) {
+	if rs.sess != nil && rs.sess.Modified() {
+		rs.sess.Save(w, rs.r)
+	}
+}
+
+//Middleware 将session放入请求的context，可通过FromContext获取
+//session在首次获取时从请求的cookie中读取，不存在时新建；新建的session只有被修改后才会写入仓库和cookie
+//被修改的session在响应头写出之前自动保存
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs := &requestSession{m: m}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, rs))
+		rs.r = r
+		sw := &saveWriter{ResponseWriter: w, rs: rs}
+		next.ServeHTTP(sw, r)
+		sw.saveOnce()
+	})
+}
+
+//Middleware 使用默认管理器的session中间件
+func Middleware(next http.Handler) http.Handler {
+	return defaultManager.Middleware(next)
+}
+
+//FromContext 获取中间件放入context的session
+//不在中间件内或无法生成session id时返回nil
+func FromContext(ctx context.Context) *Session {
+	rs, ok := ctx.Value(contextKey{}).(*requestSession)
+	if !ok {
+		return nil
+	}
+	return rs.get()
+}
+
+//saveWriter 在响应头写出前保存session
+type saveWriter struct {
+	http.ResponseWriter
+	rs    *requestSession
+	saved bool
+}
+
+func (sw *saveWriter) saveOnce() {
+	if !sw.saved {
+		sw.saved = true
+		sw.rs.save(sw.ResponseWriter)
+	}
+}
+
+func (sw *saveWriter) WriteHeader(status int) {
+	sw.saveOnce()
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *saveWriter) Write(b []byte) (int, error) {
+	sw.saveOnce()
+	return sw.ResponseWriter.Write(b)
+}
+
+//Flush 实现http.Flusher
+func (sw *saveWriter) Flush() {
+	sw.saveOnce()
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Hijack 实现http.Hijacker，接管连接前保存session
+//session cookie只写入响应头，接管连接后需由调用方自行写出
+func (sw *saveWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	sw.saveOnce()
+	return h.Hijack()
+}
+
+//Unwrap 获取被包装的ResponseWriter，供http.ResponseController使用
+func (sw *saveWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,109 @@
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+//ErrSessionExists 新的session id已被占用
+var ErrSessionExists = errors.New("sessions: session id already exists")
+
+//Regenerator 支持原子更换session id的仓库
+//Regenerate将oldID的数据转移到newID并删除oldID；grace大于0时oldID在grace时间内仍可读取到newID的数据
+//oldID不存在时不做任何事
+type Regenerator interface {
+	Regenerate(oldID, newID string, grace time.Duration) error
+}
+
+//WithRegenerateGrace 设置更换session id后旧id仍然有效的时间，用于处理携带旧cookie的并发请求
+//默认为0，即旧id立即失效；仓库需实现Regenerator
+func WithRegenerateGrace(grace time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.grace = grace
+	}
+}
+
+//RegenerateID 为session更换新的id，并写入新的cookie
+//用于登录、权限变更等场景防止会话固定攻击
+//仓库实现了Regenerator时原子地完成转移，否则依次保存新id并删除旧id
+//尚未保存过的session只更换id，在保存时写入cookie
+func (m *Manager) RegenerateID(w http.ResponseWriter, r *http.Request, sess *Session) error {
+	newID, err := createSessionID(m.ids, m.storage)
+	if err != nil {
+		return err
+	}
+	sess.cookieName = m.cookieName
+	if sess.IsNew {
+		sess.ID = newID
+		return nil
+	}
+	oldID := sess.ID
+	if rg, ok := m.storage.(Regenerator); ok {
+		if err := rg.Regenerate(oldID, newID, m.grace); err != nil {
+			return err
+		}
+		sess.ID = newID
+		http.SetCookie(w, NewCookie(sess))
+		return nil
+	}
+	//按新session保存，由仓库写入cookie
+	sess.ID = newID
+	sess.IsNew = true
+	if err := m.storage.Save(w, r, sess); err != nil {
+		sess.ID = oldID
+		sess.IsNew = false
+		return err
+	}
+	m.storage.Del(oldID)
+	return nil
+}
+
+//RegenerateID 使用默认管理器为session更换新的id
+func RegenerateID(w http.ResponseWriter, r *http.Request, sess *Session) error {
+	return defaultManager.RegenerateID(w, r, sess)
+}
+
+//更换id后仍在宽限期内的旧id
+type idAlias struct {
+	id    string
+	until time.Time
+}
+
+//旧id到新id的映射 调用方需持有仓库的锁
+type idAliases map[string]idAlias
+
+//记录旧id在grace时间内指向新id 已指向旧id的别名一并改为指向新id
+func (a *idAliases) set(oldID, newID string, grace time.Duration) {
+	if *a == nil {
+		*a = make(idAliases)
+	}
+	for old, alias := range *a {
+		if alias.id == oldID {
+			alias.id = newID
+			(*a)[old] = alias
+		}
+	}
+	if grace > 0 {
+		(*a)[oldID] = idAlias{id: newID, until: time.Now().Add(grace)}
+	}
+}
+
+//获取仍在宽限期内的旧id指向的新id
+func (a idAliases) resolve(id string) (string, bool) {
+	alias, ok := a[id]
+	if !ok || time.Now().After(alias.until) {
+		return "", false
+	}
+	return alias.id, true
+}
+
+//删除已过宽限期的别名
+func (a idAliases) purge() {
+	now := time.Now()
+	for old, alias := range a {
+		if now.After(alias.until) {
+			delete(a, old)
+		}
+	}
+}
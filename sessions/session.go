@@ -1,18 +1,10 @@
 package sessions
 
 import (
-	"bytes"
-	"math/rand"
 	"net/http"
-	"strconv"
 	"time"
 )
 
-//cookie内存放的sessionId 键名
-var (
-	cookieSessionName = "GO_WEBSESS"
-)
-
 //CookieOptions cookie存放的基础属性
 //路径、所属域、存活时间、是否安全、只经由http传输
 type CookieOptions struct {
@@ -32,11 +24,15 @@ type Session struct {
 	storage Storage
 	IsNew   bool
 	ActTime int64
+
+	cookieName string
+	modified   bool
 }
 
 //Set 设置session值
 func (s *Session) Set(key interface{}, value interface{}) {
 	s.Values[key] = value
+	s.modified = true
 }
 
 //Get 获取session内的值
@@ -53,9 +49,21 @@ func (s *Session) Get(key interface{}) interface{} {
 func (s *Session) Del(key interface{}) {
 	if _, ok := s.Values[key]; ok {
 		delete(s.Values, key)
+		s.modified = true
 	}
 }
 
+//MarkModified 标记session已修改
+//直接修改Values时需要调用，以便中间件保存session
+func (s *Session) MarkModified() {
+	s.modified = true
+}
+
+//Modified 获取session自上次保存以来是否被修改
+func (s *Session) Modified() bool {
+	return s.modified
+}
+
 //Len 获取一个session中值的个数
 func (s *Session) Len() (n int) {
 	n = len(s.Values)
@@ -65,7 +73,9 @@ func (s *Session) Len() (n int) {
 //Save 将session保存
 func (s *Session) Save(w http.ResponseWriter, r *http.Request) {
 	s.ActTime = time.Now().Unix()
-	s.storage.Save(w, r, s)
+	if s.storage.Save(w, r, s) == nil {
+		s.modified = false
+	}
 }
 
 //GC session 垃圾回收判断
@@ -74,59 +84,36 @@ func (s *Session) GC() bool {
 }
 
 //NewCookie 生成一个新的Cookie结构
+//cookie名称取自生成session的管理器
 func NewCookie(s *Session) *http.Cookie {
+	name := s.cookieName
+	if name == "" {
+		name = defaultManager.cookieName
+	}
 	cookie := &http.Cookie{
-		Name:   cookieSessionName,
-		Value:  s.ID,
-		Path:   s.Options.Path,
-		Domain: s.Options.Domain,
-		Secure: s.Options.Secure,
-		MaxAge: s.Options.MaxAge,
+		Name:     name,
+		Value:    s.ID,
+		Path:     s.Options.Path,
+		Domain:   s.Options.Domain,
+		Secure:   s.Options.Secure,
+		HttpOnly: s.Options.HTTPOnly,
+		MaxAge:   s.Options.MaxAge,
 	}
 	return cookie
 }
 
-//随机数因子
-//用以解决windows下出现的同一时刻
-//会产生同一随机数的问题
-var randSeed int64 = 0
-
-//生成随机sessionId
-func createSessionID() string {
-	rand.Seed(time.Now().UnixNano())
-	var result bytes.Buffer
-	var temp string
-	for i := 0; i < 10; {
-		temp = getChar()
-		result.WriteString(temp)
-		i++
-	}
-	randSeed++
-	return result.String()
-}
-
-//获取随机字符串
-func getChar() string {
-	switch rand.Intn(3) {
-	case 0:
-		return string(65 + rand.Intn(90-65))
-	case 1:
-		return string(97 + rand.Intn(122-97))
-	default:
-		return strconv.Itoa(rand.Intn(9))
-	}
-}
-
-//Init 初始化引擎
+//Init 初始化默认管理器的仓库与cookie名称，并启动仓库的过期回收
 func Init(store Storage, cookieName ...string) {
 	if len(cookieName) > 0 {
-		cookieSessionName = cookieName[0]
+		defaultManager.cookieName = cookieName[0]
 	}
-	storage = store
-	storage.GC()
+	defaultManager.storage = store
+	defaultManager.bindStorage()
+	store.GC()
 }
 
-//SetCookieSessionName 设置cookieSessionName 来取代默认值
+//SetCookieSessionName 设置默认管理器的cookie名称 来取代默认值
 func SetCookieSessionName(s string) {
-	cookieSessionName = s
+	defaultManager.cookieName = s
+	defaultManager.bindStorage()
 }
@@ -1,10 +1,16 @@
 package sessions
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func initMem(store Storage) {
@@ -34,7 +40,7 @@ func TestMemSessions(t *testing.T) {
 	fmt.Println("Gc:", isGc)
 
 	id := newSession.ID
-	name := cookieSessionName
+	name := Default().CookieName()
 
 	resp := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "localhost:8999", nil)
@@ -87,7 +93,7 @@ func TestFileSessions(t *testing.T) {
 	newSession.Save(resp, nil)
 
 	id := newSession.ID
-	name := cookieSessionName
+	name := Default().CookieName()
 
 	req.AddCookie(&http.Cookie{
 		Name:     name,
@@ -107,3 +113,491 @@ func TestFileSessions(t *testing.T) {
 
 	DelSession(resp, newSession)
 }
+
+//按顺序返回预设id的生成器
+type fixedIDGenerator struct {
+	ids []string
+}
+
+func (g *fixedIDGenerator) NewID() (string, error) {
+	id := g.ids[0]
+	if len(g.ids) > 1 {
+		g.ids = g.ids[1:]
+	}
+	return id, nil
+}
+
+func TestRandomIDGenerator(t *testing.T) {
+	for bits, size := range map[int]int{0: 22, 128: 22, 256: 43, 60: 11} {
+		id, err := NewRandomIDGenerator(bits).NewID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != size {
+			t.Errorf("bits %d: id %q has length %d, want %d", bits, id, len(id), size)
+		}
+		if strings.Trim(id, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+			t.Errorf("id %q is not url safe", id)
+		}
+	}
+	seen := make(map[string]bool)
+	gen := NewRandomIDGenerator(DefaultIDBits)
+	for i := 0; i < 10000; i++ {
+		id, _ := gen.NewID()
+		if seen[id] {
+			t.Fatalf("duplicate id %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+//从请求中读取数据的仓库，没有实现Exister
+type requestStorage struct {
+	Storage
+}
+
+func (s requestStorage) Get(r *http.Request, id string) (*Session, error) {
+	if _, err := r.Cookie("extra"); err != nil {
+		return nil, err
+	}
+	return s.Storage.Get(r, id)
+}
+
+func TestSessionIDCollision(t *testing.T) {
+	store := NewMemSessionStorage()
+	store.Save(httptest.NewRecorder(), nil, &Session{ID: "taken", Options: &CookieOptions{}, Values: map[interface{}]interface{}{}})
+
+	id, err := createSessionID(&fixedIDGenerator{ids: []string{"taken", "taken", "free"}}, store)
+	if err != nil || id != "free" {
+		t.Fatalf("id = %q err = %v, want free", id, err)
+	}
+	if _, err := createSessionID(&fixedIDGenerator{ids: []string{"taken"}}, store); err != ErrIDCollision {
+		t.Fatalf("err = %v, want ErrIDCollision", err)
+	}
+	if _, ok := NewFileSessionStorage(t.TempDir(), "sess_").(Exister); !ok {
+		t.Error("file storage should check for collisions")
+	}
+	//未实现Exister的仓库不检查冲突，不会以nil请求调用Get
+	id, err = createSessionID(&fixedIDGenerator{ids: []string{"taken"}}, requestStorage{store})
+	if err != nil || id != "taken" {
+		t.Fatalf("id = %q err = %v, want taken", id, err)
+	}
+
+	defer SetIDGenerator(defaultManager.ids)
+	SetIDGenerator(&fixedIDGenerator{ids: []string{"taken", "custom"}})
+	Init(store, "TEST")
+	if s := NewSession("/", "", 300, false, true); s.ID != "custom" {
+		t.Fatalf("session id = %q, want custom", s.ID)
+	}
+}
+
+func TestManager(t *testing.T) {
+	admin := NewManager(NewMemSessionStorage(), WithCookieName("ADMIN"),
+		WithCookieOptions(CookieOptions{Path: "/admin", MaxAge: 600, Secure: true, HTTPOnly: true}))
+	public := NewManager(NewMemSessionStorage(), WithIDGenerator(NewRandomIDGenerator(256)))
+	defer admin.Close()
+	defer public.Close()
+
+	sess, err := admin.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.Set("user", "root")
+	resp := httptest.NewRecorder()
+	sess.Save(resp, nil)
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "ADMIN" || cookies[0].Path != "/admin" || !cookies[0].Secure || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.AddCookie(cookies[0])
+	got, err := admin.Get(req)
+	if err != nil || got.Get("user") != "root" {
+		t.Fatalf("admin get: %v %v", got, err)
+	}
+	//不同管理器的session互不可见
+	if _, err := public.Get(req); err != ErrNoSession {
+		t.Fatalf("public get: %v, want ErrNoSession", err)
+	}
+	pub, _ := public.New()
+	if len(pub.ID) != 43 || NewCookie(pub).Name != defaultCookieName {
+		t.Fatalf("public session id %q cookie %q", pub.ID, NewCookie(pub).Name)
+	}
+
+	resp = httptest.NewRecorder()
+	admin.Destroy(resp, got)
+	if c := resp.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Fatalf("destroy cookies = %+v", c)
+	}
+	if _, err := admin.Get(req); err == nil {
+		t.Fatal("destroyed session should be gone")
+	}
+}
+
+func TestMemStorageGC(t *testing.T) {
+	store := NewMemSessionStorage().(*MemStorage)
+	m := NewManager(store)
+	sess, _ := m.New()
+	sess.Options.MaxAge = -1
+	sess.Save(httptest.NewRecorder(), nil)
+	store.GC()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, err := store.Get(nil, sess.ID); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired session was not collected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	m.Close()
+	m.Close()
+}
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemSessionStorage()
+	m := NewManager(store, WithCookieName("MW"))
+	defer m.Close()
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			FromContext(r.Context()).Set("user", "alice")
+			w.Write([]byte("ok"))
+		case "/whoami":
+			user, _ := FromContext(r.Context()).Get("user").(string)
+			w.Write([]byte(user))
+		case "/late":
+			w.WriteHeader(http.StatusNoContent)
+			FromContext(r.Context()).Set("late", true)
+		case "/silent":
+			FromContext(r.Context()).Set("silent", true)
+		}
+	}))
+	serve := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	//只读取不修改时不创建session
+	if w := serve("/whoami"); len(w.Result().Cookies()) != 0 {
+		t.Fatal("unmodified new session should not be saved")
+	}
+	w := serve("/login")
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "MW" {
+		t.Fatalf("login cookies = %+v", cookies)
+	}
+	if w := serve("/whoami", cookies[0]); w.Body.String() != "alice" || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("whoami = %q cookies = %v", w.Body, w.Result().Cookies())
+	}
+	//响应头写出后的修改不会保存
+	if w := serve("/late"); len(w.Result().Cookies()) != 0 {
+		t.Fatal("changes after the header is written should not be saved")
+	}
+	//没有写出响应时在处理结束后保存
+	if w := serve("/silent"); len(w.Result().Cookies()) != 1 {
+		t.Fatal("session should be saved when the handler writes nothing")
+	}
+	if FromContext(context.Background()) != nil {
+		t.Fatal("FromContext outside the middleware should be nil")
+	}
+}
+
+//支持接管连接的ResponseRecorder
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	c, _ := net.Pipe()
+	return c, bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c)), nil
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	m := NewManager(NewMemSessionStorage(), WithCookieName("HJ"))
+	defer m.Close()
+	var inner http.ResponseWriter
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Set("user", "alice")
+		inner = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !rec.hijacked || inner != http.ResponseWriter(rec) {
+		t.Fatal("hijack and unwrap should reach the underlying writer")
+	}
+	if !strings.Contains(rec.Header().Get("Set-Cookie"), "HJ=") {
+		t.Fatal("session should be saved before the connection is hijacked")
+	}
+
+	//不支持接管连接时返回ErrNotSupported
+	h = m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := w.(http.Hijacker).Hijack(); err != http.ErrNotSupported {
+			t.Errorf("hijack err = %v", err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestDefaultMiddleware(t *testing.T) {
+	store := NewMemSessionStorage().(*MemStorage)
+	defer func(s Storage, name string) {
+		CunstomSessionStorage(s)
+		SetCookieSessionName(name)
+	}(Default().Storage(), Default().CookieName())
+	CunstomSessionStorage(store)
+	SetCookieSessionName("DEFMW")
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess := FromContext(r.Context())
+		if r.URL.Path == "/login" {
+			sess.Set("user", "alice")
+			return
+		}
+		user, _ := sess.Get("user").(string)
+		w.Write([]byte(user))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Path != "/" || cookies[0].MaxAge != 3600 || !cookies[0].HttpOnly {
+		t.Fatalf("login cookies = %+v", cookies)
+	}
+	//默认的存活时间为3600秒，几秒前活跃的session不应被回收
+	store.rwLock.Lock()
+	for _, sess := range store.list {
+		sess.ActTime -= 10
+		if sess.GC() {
+			t.Error("session should not expire within its max age")
+		}
+	}
+	store.rwLock.Unlock()
+
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Body.String() != "alice" {
+		t.Fatalf("whoami = %q", w.Body)
+	}
+}
+
+func TestRegenerateID(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]Storage{
+		"mem":  NewMemSessionStorage(),
+		"file": NewFileSessionStorage(dir, "sess_"),
+	}
+	for name, store := range stores {
+		for _, grace := range []time.Duration{0, time.Hour} {
+			m := NewManager(store, WithRegenerateGrace(grace))
+			sess, _ := m.New()
+			sess.Set("user", "alice")
+			sess.Save(httptest.NewRecorder(), nil)
+			oldID := sess.ID
+
+			w := httptest.NewRecorder()
+			if err := m.RegenerateID(w, nil, sess); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if sess.ID == oldID {
+				t.Fatalf("%s: id was not changed", name)
+			}
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Value != sess.ID {
+				t.Fatalf("%s: cookies = %+v", name, cookies)
+			}
+			got, err := store.Get(nil, sess.ID)
+			if err != nil || got.Get("user") != "alice" || got.ID != sess.ID {
+				t.Fatalf("%s: new id lookup = %v %v", name, got, err)
+			}
+			old, err := store.Get(nil, oldID)
+			if grace == 0 && err == nil {
+				t.Fatalf("%s: old id should be invalid", name)
+			}
+			if grace > 0 && (err != nil || old.ID != sess.ID) {
+				t.Fatalf("%s: old id should resolve to the new session during grace, got %v %v", name, old, err)
+			}
+			m.Close()
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("%d session files, want 2", len(entries))
+	}
+
+	//仓库未实现Regenerator时依次保存与删除
+	plain := NewManager(struct{ Storage }{NewMemSessionStorage()})
+	defer plain.Close()
+	sess, _ := plain.New()
+	sess.Save(httptest.NewRecorder(), nil)
+	oldID := sess.ID
+	if err := plain.RegenerateID(httptest.NewRecorder(), nil, sess); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Storage().Get(nil, oldID); err == nil {
+		t.Fatal("old id should be deleted")
+	}
+	if _, err := plain.Storage().Get(nil, sess.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	//未保存过的session只更换id
+	m := NewManager(NewMemSessionStorage())
+	defer m.Close()
+	sess, _ = m.New()
+	oldID = sess.ID
+	w := httptest.NewRecorder()
+	if err := m.RegenerateID(w, nil, sess); err != nil || sess.ID == oldID || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("new session: err = %v cookies = %v", err, w.Result().Cookies())
+	}
+}
+
+func TestCookieStorage(t *testing.T) {
+	oldKey := []byte("0123456789abcdef")
+	newKey := []byte("fedcba9876543210fedcba9876543210")
+	if _, err := NewCookieStorage([]byte("short")); err == nil {
+		t.Fatal("invalid key length should be rejected")
+	}
+	oldStore, _ := NewCookieStorage(oldKey)
+	store, err := NewCookieStorage(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(store, WithCookieName("CS"))
+
+	request := func(cookies []*http.Cookie) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		return req
+	}
+	save := func(s Storage, sess *Session) []*http.Cookie {
+		w := httptest.NewRecorder()
+		if err := s.Save(w, nil, sess); err != nil {
+			t.Fatal(err)
+		}
+		return w.Result().Cookies()
+	}
+
+	sess, _ := m.New()
+	sess.Set("user", "alice")
+	sess.ActTime = time.Now().Unix()
+	cookies := save(store, sess)
+	if len(cookies) != 1 || cookies[0].Name != "CS" || strings.Contains(cookies[0].Value, "alice") {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+	got, err := m.Get(request(cookies))
+	if err != nil || got.Get("user") != "alice" || got.ID != sess.ID {
+		t.Fatalf("get = %v %v", got, err)
+	}
+
+	//篡改与改名的cookie无法解密
+	tampered := *cookies[0]
+	tampered.Value = tampered.Value[:len(tampered.Value)-2] + "AA"
+	if _, err := m.Get(request([]*http.Cookie{&tampered})); err != ErrCookieInvalid {
+		t.Fatalf("tampered cookie: %v", err)
+	}
+	renamed := *cookies[0]
+	renamed.Name = "OTHER"
+	otherStore, _ := NewCookieStorage(newKey)
+	if _, err := NewManager(otherStore, WithCookieName("OTHER")).Get(request([]*http.Cookie{&renamed})); err != ErrCookieInvalid {
+		t.Fatalf("renamed cookie: %v", err)
+	}
+
+	//轮换前的密钥签发的cookie仍可读取
+	sess.cookieName = "CS"
+	if _, err := m.Get(request(save(oldStore, sess))); err != nil {
+		t.Fatalf("old key: %v", err)
+	}
+	if _, err := NewManager(oldStore, WithCookieName("CS")).Get(request(cookies)); err != ErrCookieInvalid {
+		t.Fatal("old store should not read cookies sealed with the new key")
+	}
+
+	//过期时间以cookie内的时间戳为准
+	sess.ActTime = time.Now().Unix() - int64(sess.Options.MaxAge) - 1
+	if _, err := m.Get(request(save(store, sess))); err != ErrCookieExpired {
+		t.Fatalf("expired cookie: %v", err)
+	}
+	//MaxAge为0的cookie按DefaultTTL过期
+	sess.Options.MaxAge = 0
+	sess.ActTime = time.Now().Unix() - int64(DefaultCookieTTL/time.Second) - 1
+	if _, err := m.Get(request(save(store, sess))); err != ErrCookieExpired {
+		t.Fatalf("session cookie without max age: %v", err)
+	}
+	store.DefaultTTL = 48 * time.Hour
+	if _, err := m.Get(request(save(store, sess))); err != nil {
+		t.Fatalf("default ttl: %v", err)
+	}
+	sess.Options.MaxAge = 3600
+	sess.ActTime = time.Now().Unix()
+
+	//超出单个cookie时拒绝或拆分
+	sess.Set("blob", strings.Repeat("x", 2*CookieValueLimit))
+	if err := store.Save(httptest.NewRecorder(), nil, sess); err != ErrCookieTooLarge {
+		t.Fatalf("large session: %v", err)
+	}
+	store.MaxChunks = 4
+	chunks := save(store, sess)
+	if len(chunks) < 3 || chunks[1].Name != "CS_1" {
+		t.Fatalf("chunks = %d", len(chunks))
+	}
+	for _, c := range chunks {
+		if len(c.String()) > 4096 {
+			t.Fatalf("cookie %s is %d bytes", c.Name, len(c.String()))
+		}
+	}
+	got, err = m.Get(request(chunks))
+	if err != nil || len(got.Get("blob").(string)) != 2*CookieValueLimit {
+		t.Fatalf("chunked get: %v", err)
+	}
+	if _, err := m.Get(request(chunks[:len(chunks)-1])); err != ErrCookieInvalid {
+		t.Fatalf("missing chunk: %v", err)
+	}
+	//销毁时删除所有分块
+	w := httptest.NewRecorder()
+	m.Destroy(w, got)
+	if destroyed := w.Result().Cookies(); len(destroyed) != store.MaxChunks || destroyed[3].Name != "CS_3" || destroyed[3].MaxAge >= 0 {
+		t.Fatalf("destroy cookies = %+v", destroyed)
+	}
+	//变小后删除多余的分块
+	sess.Del("blob")
+	w = httptest.NewRecorder()
+	store.Save(w, request(chunks), sess)
+	expired := 0
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			expired++
+		}
+	}
+	if expired != len(chunks)-1 {
+		t.Fatalf("expired %d stale chunks, want %d", expired, len(chunks)-1)
+	}
+
+	//更换id时由仓库写入新的cookie
+	w = httptest.NewRecorder()
+	oldID := sess.ID
+	if err := m.RegenerateID(w, nil, sess); err != nil {
+		t.Fatal(err)
+	}
+	got, err = m.Get(request(w.Result().Cookies()))
+	if err != nil || got.ID == oldID || got.ID != sess.ID {
+		t.Fatalf("regenerated: %v %v", got, err)
+	}
+}
@@ -0,0 +1,262 @@
+package sessions
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CookieValueLimit 单个cookie值的最大长度
+//浏览器限制每个cookie（名称、值与属性）不超过4096字节，为名称与属性预留空间
+const CookieValueLimit = 3800
+
+//DefaultCookieTTL MaxAge为0（浏览器会话cookie）时加密内容中记录的默认存活时间
+const DefaultCookieTTL = 24 * time.Hour
+
+var (
+	//ErrCookieTooLarge session加密后超出允许的cookie数量
+	ErrCookieTooLarge = errors.New("sessions: session too large for cookie storage")
+	//ErrCookieInvalid cookie无法被任何密钥解密或格式错误
+	ErrCookieInvalid = errors.New("sessions: invalid session cookie")
+	//ErrCookieExpired cookie内记录的过期时间已过
+	ErrCookieExpired = errors.New("sessions: session cookie expired")
+)
+
+//CookieStorage 加密cookie session仓库
+//整个session以AES-GCM加密并认证后保存在客户端cookie中，服务端不保存任何状态
+//超出单个cookie长度时拆分为多个cookie（名称依次为name_1、name_2……），超出MaxChunks时Save返回ErrCookieTooLarge
+//过期时间总是记录在加密内容中，不依赖浏览器对cookie的过期处理
+//由于服务端无状态，Del无法让已签发的cookie失效，只能通过让客户端删除cookie
+//cookie名称由使用该仓库的Manager设置，一个CookieStorage只应被一个Manager使用
+type CookieStorage struct {
+	aeads []cipher.AEAD //第一个用于加密，全部用于解密
+	name  string        //cookie名称，为空时使用默认名称
+
+	//MaxChunks 一个session最多使用的cookie数量，默认为1即不拆分
+	MaxChunks int
+	//DefaultTTL session的MaxAge为0时cookie内容的存活时间，默认为DefaultCookieTTL
+	DefaultTTL time.Duration
+}
+
+//cookie内加密保存的session内容
+type cookiePayload struct {
+	ID      string
+	Values  map[string]interface{}
+	Options *CookieOptions
+	ActTime int64
+	Expires int64 //过期时间的Unix秒
+}
+
+//NewCookieStorage 生成一个加密cookie session仓库
+//key为当前的加密密钥，oldKeys为轮换前的密钥，只用于解密已签发的cookie
+//密钥长度需为16、24或32字节，分别对应AES-128、AES-192与AES-256
+func NewCookieStorage(key []byte, oldKeys ...[]byte) (*CookieStorage, error) {
+	cs := &CookieStorage{MaxChunks: 1, DefaultTTL: DefaultCookieTTL}
+	for _, k := range append([][]byte{key}, oldKeys...) {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		cs.aeads = append(cs.aeads, aead)
+	}
+	return cs, nil
+}
+
+//Save 加密session并写入cookie
+//每次保存都会重新写入cookie，并删除请求中多余的分块cookie
+func (cs *CookieStorage) Save(w http.ResponseWriter, r *http.Request, sess *Session) error {
+	cookie := NewCookie(sess)
+	payload := cookiePayload{
+		ID:      sess.ID,
+		Values:  make(map[string]interface{}, len(sess.Values)),
+		Options: sess.Options,
+		ActTime: sess.ActTime,
+	}
+	for k, v := range sess.Values {
+		if key, ok := k.(string); ok {
+			payload.Values[key] = v
+		}
+	}
+	//MaxAge为0时浏览器在关闭前一直保留cookie，仍需在内容中限制存活时间
+	ttl := int64(sess.Options.MaxAge)
+	if ttl == 0 {
+		ttl = int64(cs.DefaultTTL / time.Second)
+		if ttl <= 0 {
+			ttl = int64(DefaultCookieTTL / time.Second)
+		}
+	}
+	payload.Expires = sess.ActTime + ttl
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	value, err := cs.seal(cookie.Name, data)
+	if err != nil {
+		return err
+	}
+	chunks := splitChunks(value)
+	maxChunks := cs.MaxChunks
+	if maxChunks < 1 {
+		maxChunks = 1
+	}
+	if len(chunks) > maxChunks {
+		return ErrCookieTooLarge
+	}
+	if len(chunks) > 1 {
+		chunks[0] = strconv.Itoa(len(chunks)) + "." + chunks[0]
+	}
+	for i, chunk := range chunks {
+		c := *cookie
+		c.Name = chunkName(cookie.Name, i)
+		c.Value = chunk
+		http.SetCookie(w, &c)
+	}
+	if r != nil {
+		for i := len(chunks); ; i++ {
+			old, err := r.Cookie(chunkName(cookie.Name, i))
+			if err != nil {
+				break
+			}
+			http.SetCookie(w, &http.Cookie{Name: old.Name, Path: cookie.Path, Domain: cookie.Domain, MaxAge: -1})
+		}
+	}
+	sess.IsNew = false
+	return nil
+}
+
+//Get 解密cookie中的session
+//value为请求中session cookie的值，分块的其余部分按仓库的cookie名称从请求中读取
+func (cs *CookieStorage) Get(r *http.Request, value string) (*Session, error) {
+	if r == nil {
+		return nil, ErrCookieInvalid
+	}
+	name := cs.cookieName()
+	if i := strings.IndexByte(value, '.'); i >= 0 {
+		n, err := strconv.Atoi(value[:i])
+		if err != nil || n < 2 {
+			return nil, ErrCookieInvalid
+		}
+		parts := []string{value[i+1:]}
+		for j := 1; j < n; j++ {
+			c, err := r.Cookie(chunkName(name, j))
+			if err != nil {
+				return nil, ErrCookieInvalid
+			}
+			parts = append(parts, c.Value)
+		}
+		value = strings.Join(parts, "")
+	}
+	data, err := cs.open(name, value)
+	if err != nil {
+		return nil, err
+	}
+	var payload cookiePayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrCookieInvalid
+	}
+	if time.Now().Unix() >= payload.Expires {
+		return nil, ErrCookieExpired
+	}
+	sess := &Session{
+		ID:         payload.ID,
+		Values:     make(map[interface{}]interface{}, len(payload.Values)),
+		Options:    payload.Options,
+		ActTime:    payload.ActTime,
+		storage:    cs,
+		cookieName: name,
+	}
+	if sess.Options == nil {
+		sess.Options = &CookieOptions{}
+	}
+	for k, v := range payload.Values {
+		sess.Values[k] = v
+	}
+	return sess, nil
+}
+
+//Del 服务端不保存session，无需删除
+func (cs *CookieStorage) Del(string) {}
+
+//GC 过期由cookie内的时间戳判断，无需回收
+func (cs *CookieStorage) GC() {}
+
+//由Manager设置cookie名称
+func (cs *CookieStorage) setCookieName(name string) {
+	cs.name = name
+}
+
+func (cs *CookieStorage) cookieName() string {
+	if cs.name == "" {
+		return defaultCookieName
+	}
+	return cs.name
+}
+
+//让客户端删除session的所有分块cookie 主cookie由Manager删除
+func (cs *CookieStorage) expireCookies(w http.ResponseWriter, sess *Session) {
+	cookie := NewCookie(sess)
+	for i := 1; i < cs.MaxChunks; i++ {
+		http.SetCookie(w, &http.Cookie{Name: chunkName(cookie.Name, i), Path: cookie.Path, Domain: cookie.Domain, MaxAge: -1})
+	}
+}
+
+//加密数据 cookie名称作为附加数据，防止cookie被改名使用
+func (cs *CookieStorage) seal(name string, data []byte) (string, error) {
+	aead := cs.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, data, []byte(name))), nil
+}
+
+//依次尝试所有密钥解密
+func (cs *CookieStorage) open(name, value string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrCookieInvalid
+	}
+	for _, aead := range cs.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if data, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return data, nil
+		}
+	}
+	return nil, ErrCookieInvalid
+}
+
+//按CookieValueLimit拆分 首块预留分块数量前缀的长度
+func splitChunks(value string) []string {
+	if len(value) <= CookieValueLimit {
+		return []string{value}
+	}
+	var chunks []string
+	limit := CookieValueLimit - 8
+	for len(value) > limit {
+		chunks = append(chunks, value[:limit])
+		value = value[limit:]
+	}
+	return append(chunks, value)
+}
+
+//第i个分块的cookie名称
+func chunkName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return name + "_" + strconv.Itoa(i)
+}
@@ -19,7 +19,9 @@ type FileStorage struct {
 	storagePath string
 	prefix      string
 	list        map[string]string
+	aliases     idAliases
 	rwLock      sync.RWMutex
+	gc          gcLoop
 }
 
 //一个临时的文件session结构
@@ -67,6 +69,9 @@ func (fs *FileStorage) Save(w http.ResponseWriter, r *http.Request, sess *Sessio
 func (fs *FileStorage) Get(r *http.Request, name string) (*Session, error) {
 	fs.rwLock.RLock()
 	defer fs.rwLock.RUnlock()
+	if id, ok := fs.aliases.resolve(name); ok {
+		name = id
+	}
 	//从map中获取session文件名 然后读取session文件
 	if sessName, ok := fs.list[name]; ok {
 		content, err := fs.readSessionFile(sessName)
@@ -79,47 +84,93 @@ func (fs *FileStorage) Get(r *http.Request, name string) (*Session, error) {
 	return nil, errors.New("session lost")
 }
 
+//Exists 检查session id是否已被占用，包括更换id后仍在宽限期内的旧id
+func (fs *FileStorage) Exists(name string) bool {
+	fs.rwLock.RLock()
+	defer fs.rwLock.RUnlock()
+	if _, ok := fs.list[name]; ok {
+		return true
+	}
+	_, ok := fs.aliases.resolve(name)
+	return ok
+}
+
 //Del 删除session 并将对应的session文件删除
 func (fs *FileStorage) Del(name string) {
 	fs.rwLock.Lock()
 	defer fs.rwLock.Unlock()
 	if filename, ok := fs.list[name]; ok {
 		delete(fs.list, name)
-		os.Remove(filename)
+		os.Remove(fs.storagePath + filename)
 	}
 }
 
 //GC session回收
-//每秒轮询list内的session数据
+//每秒轮询list内的session数据，重复调用只会启动一次
 //如果文件内容损坏、
 //如果文件丢失、
 //如果session超时
 //将filename从list中移除 并删除文件
 func (fs *FileStorage) GC() {
-	go func() {
-		for {
-			for name, filename := range fs.list {
-				content, err := fs.readSessionFile(filename)
-				if err != nil && filename != "" {
-					fs.Del(name)
-					os.Remove(fs.storagePath + filename)
-					continue
-				}
-				sess, err := fs.newSession(content)
-				if err != nil {
-					fs.Del(name)
-					os.Remove(fs.storagePath + filename)
-					continue
-				}
-				if sess.GC() {
-					fs.Del(name)
-					os.Remove(fs.storagePath + filename)
+	fs.gc.start(time.Second, func() {
+		fs.rwLock.Lock()
+		defer fs.rwLock.Unlock()
+		for name, filename := range fs.list {
+			content, err := fs.readSessionFile(filename)
+			if err == nil {
+				var sess *Session
+				if sess, err = fs.newSession(content); err == nil && !sess.GC() {
 					continue
 				}
 			}
-			time.Sleep(1 * time.Second)
+			delete(fs.list, name)
+			os.Remove(fs.storagePath + filename)
 		}
-	}()
+		fs.aliases.purge()
+	})
+}
+
+//Regenerate 原子地将oldID的session文件转移到newID
+//先写入新文件再删除旧文件，期间持有写锁，其他请求不会读到中间状态
+func (fs *FileStorage) Regenerate(oldID, newID string, grace time.Duration) error {
+	fs.rwLock.Lock()
+	defer fs.rwLock.Unlock()
+	oldName, ok := fs.list[oldID]
+	if !ok {
+		return nil
+	}
+	if _, ok := fs.list[newID]; ok {
+		return ErrSessionExists
+	}
+	content, err := fs.readSessionFile(oldName)
+	if err != nil {
+		return err
+	}
+	sess, err := fs.newSession(content)
+	if err != nil {
+		return err
+	}
+	sess.ID = newID
+	data, err := json.Marshal(fs.newFileSession(sess))
+	if err != nil {
+		return err
+	}
+	newName := fs.prefix + newID
+	if err := fs.writeSessionFile(newName, string(data)); err != nil {
+		os.Remove(fs.storagePath + newName)
+		return err
+	}
+	fs.list[newID] = newName
+	delete(fs.list, oldID)
+	os.Remove(fs.storagePath + oldName)
+	fs.aliases.set(oldID, newID, grace)
+	return nil
+}
+
+//Close 停止session回收
+func (fs *FileStorage) Close() error {
+	fs.gc.stop()
+	return nil
 }
 
 //从session文件中读取内容
@@ -177,7 +228,7 @@ func (fs *FileStorage) newSession(content string) (*Session, error) {
 		return nil, err
 	}
 	return &Session{
-		storage: storage,
+		storage: fs,
 		ID:      session.ID,
 		ActTime: session.ActTime,
 		Options: session.Options,
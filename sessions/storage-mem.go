@@ -10,8 +10,10 @@ import (
 //MemStorage 实现一个仓库接口
 //session存储于内存中，服务重启后丢失
 type MemStorage struct {
-	list   map[string]*Session
-	rwLock sync.RWMutex
+	list    map[string]*Session
+	aliases idAliases
+	rwLock  sync.RWMutex
+	gc      gcLoop
 }
 
 //Save 仓库save方法 将sessionId数据写入cookie返回到客户端
@@ -34,6 +36,9 @@ func (ms *MemStorage) Save(w http.ResponseWriter, r *http.Request, sess *Session
 func (ms *MemStorage) Get(r *http.Request, name string) (*Session, error) {
 	ms.rwLock.RLock()
 	defer ms.rwLock.RUnlock()
+	if id, ok := ms.aliases.resolve(name); ok {
+		name = id
+	}
 	if sess, ok := ms.list[name]; ok {
 		return &Session{
 			ID:      sess.ID,
@@ -41,12 +46,23 @@ func (ms *MemStorage) Get(r *http.Request, name string) (*Session, error) {
 			Options: sess.Options,
 			IsNew:   false,
 			ActTime: sess.ActTime,
-			storage: storage,
+			storage: ms,
 		}, nil
 	}
 	return nil, errors.New("session lost")
 }
 
+//Exists 检查session id是否已被占用，包括更换id后仍在宽限期内的旧id
+func (ms *MemStorage) Exists(name string) bool {
+	ms.rwLock.RLock()
+	defer ms.rwLock.RUnlock()
+	if _, ok := ms.list[name]; ok {
+		return true
+	}
+	_, ok := ms.aliases.resolve(name)
+	return ok
+}
+
 //Del 从仓库中删除一个session
 func (ms *MemStorage) Del(name string) {
 	ms.rwLock.Lock()
@@ -55,21 +71,42 @@ func (ms *MemStorage) Del(name string) {
 }
 
 //GC 仓库内过期session清除
-//每秒钟筛选一遍
+//每秒钟筛选一遍，重复调用只会启动一次
 func (ms *MemStorage) GC() {
-	go func() {
-		for {
-			for _, session := range ms.list {
-				if session.GC() {
-					ms.rwLock.Lock()
-					ms.Del(session.ID)
-					ms.rwLock.Unlock()
-				}
+	ms.gc.start(time.Second, func() {
+		ms.rwLock.Lock()
+		defer ms.rwLock.Unlock()
+		for name, session := range ms.list {
+			if session.GC() {
+				delete(ms.list, name)
 			}
-			time.Sleep(1 * time.Second)
 		}
-	}()
+		ms.aliases.purge()
+	})
+}
 
+//Regenerate 原子地将oldID的session转移到newID
+func (ms *MemStorage) Regenerate(oldID, newID string, grace time.Duration) error {
+	ms.rwLock.Lock()
+	defer ms.rwLock.Unlock()
+	sess, ok := ms.list[oldID]
+	if !ok {
+		return nil
+	}
+	if _, ok := ms.list[newID]; ok {
+		return ErrSessionExists
+	}
+	delete(ms.list, oldID)
+	sess.ID = newID
+	ms.list[newID] = sess
+	ms.aliases.set(oldID, newID, grace)
+	return nil
+}
+
+//Close 停止过期回收
+func (ms *MemStorage) Close() error {
+	ms.gc.stop()
+	return nil
 }
 
 //NewMemSessionStorage 生成一个新的内存session仓库
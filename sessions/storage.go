@@ -1,8 +1,8 @@
 package sessions
 
 import (
-	"errors"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -15,40 +15,67 @@ type Storage interface {
 	GC()
 }
 
-//session仓库
-var storage Storage
-
-//NewSession 生成一个新的session类
+//NewSession 使用默认管理器生成一个新的session类
+//无法生成session id时（随机数源不可用或id连续冲突）会panic
 func NewSession(path, domain string, maxage int, secure, httponly bool) *Session {
-	cookieOpt := &CookieOptions{path, domain, maxage, secure, httponly}
-	id := createSessionID()
-	return &Session{
-		ID:      id,
-		Options: cookieOpt,
-		storage: storage,
-		Values:  make(map[interface{}]interface{}),
-		IsNew:   true,
-		ActTime: time.Now().Unix(),
+	sess, err := defaultManager.newSession(&CookieOptions{path, domain, maxage, secure, httponly})
+	if err != nil {
+		panic(err.Error())
 	}
+	return sess
 }
 
-//GetSession 从请求中获取session
+//GetSession 使用默认管理器从请求中获取session
 func GetSession(r *http.Request) (*Session, error) {
-	cookie, err := r.Cookie(cookieSessionName)
-	if err == nil {
-		return storage.Get(r, cookie.Value)
-	}
-	return nil, errors.New("no session")
+	return defaultManager.Get(r)
 }
 
-//DelSession 主动删除session
+//DelSession 使用默认管理器主动删除session
 func DelSession(w http.ResponseWriter, sess *Session) {
-	storage.Del(sess.ID)
-	sess.Options.MaxAge = -1
-	http.SetCookie(w, NewCookie(sess))
+	defaultManager.Destroy(w, sess)
 }
 
-//CunstomSessionStorage 自定义存储引擎
+//CunstomSessionStorage 设置默认管理器的存储引擎
 func CunstomSessionStorage(store Storage) {
-	storage = store
+	defaultManager.storage = store
+	defaultManager.bindStorage()
+}
+
+//仓库的定时回收协程 可启动一次并停止
+type gcLoop struct {
+	once sync.Once
+	lock sync.Mutex
+	done chan struct{}
+}
+
+//启动定时回收 重复调用只会启动一次
+func (g *gcLoop) start(interval time.Duration, fn func()) {
+	g.once.Do(func() {
+		g.lock.Lock()
+		g.done = make(chan struct{})
+		done := g.done
+		g.lock.Unlock()
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				fn()
+				select {
+				case <-ticker.C:
+				case <-done:
+					return
+				}
+			}
+		}()
+	})
+}
+
+//停止定时回收
+func (g *gcLoop) stop() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.done != nil {
+		close(g.done)
+		g.done = nil
+	}
 }